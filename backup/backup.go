@@ -1,6 +1,7 @@
 package backup
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"os"
@@ -8,12 +9,44 @@ import (
 	"time"
 
 	"github.com/lizhening/WtfBackup/config"
+	"github.com/lizhening/WtfBackup/pkg/detect"
 	"github.com/lizhening/WtfBackup/pkg/fileutil"
 	"github.com/lizhening/WtfBackup/pkg/logger"
+	"github.com/lizhening/WtfBackup/pkg/manifest"
+	"github.com/lizhening/WtfBackup/pkg/process"
+	"github.com/lizhening/WtfBackup/pkg/progress"
+	"github.com/lizhening/WtfBackup/pkg/snapshot"
+	"github.com/lizhening/WtfBackup/pkg/store"
 )
 
-// BackupWtf 备份WTF文件夹
-func BackupWtf(cfg config.Config, fileOp fileutil.FileOperator, showProgress bool) error {
+// Options 备份选项
+type Options struct {
+	// 显示进度条
+	ShowProgress bool
+	// 固定这个备份，清理旧备份时不会删除
+	Pin bool
+	// 备份的标签，可以通过 label:<标签> 选择备份
+	Label string
+	// 备份的备注
+	Note string
+	// 程序版本，记录在备份信息中
+	Version string
+	// 检查游戏是否正在运行时使用的进程列表，为 nil 时使用 process.Default
+	Processes process.Lister
+}
+
+// gameWaitInterval 等待游戏退出时检查的间隔
+const gameWaitInterval = 5 * time.Second
+
+// BackupWtf 备份WTF文件夹，ctx 被取消（例如按下 Ctrl-C）时停止备份并删除未完成的备份。
+// 开始和结束时发布 start 和 done 事件
+func BackupWtf(ctx context.Context, cfg config.Config, fileOp fileutil.FileOperator, opts Options) (err error) {
+	var summary progress.Summary
+	progress.Emit(progress.Event{Type: progress.EventStart, Op: "backup", Path: cfg.WtfPath})
+	defer func() {
+		progress.Emit(progress.Done("backup", summary, err))
+	}()
+
 	// 验证WTF文件夹存在
 	info, err := os.Stat(cfg.WtfPath)
 	if err != nil {
@@ -23,26 +56,319 @@ func BackupWtf(cfg config.Config, fileOp fileutil.FileOperator, showProgress boo
 		return fmt.Errorf("%s 不是一个文件夹", cfg.WtfPath)
 	}
 
-	// 生成备份文件夹名称，格式为 WTF_Backup_YYYY-MM-DD_HH-MM-SS
+	format, err := snapshot.ParseFormat(cfg.Format)
+	if err != nil {
+		return err
+	}
+	if cfg.Incremental && format != snapshot.FormatDir {
+		warn("增量备份仅支持文件夹格式，%s 格式将进行完整备份", format)
+	}
+
+	// 游戏会在退出或重载界面时写入 SavedVariables，运行时备份可能得到过时或只写了一半的文件
+	running, err := checkGame(ctx, cfg, opts, fileutil.IsDryRun(fileOp))
+	if err != nil {
+		return err
+	}
+
+	// 清理上次中断的备份
+	if !fileutil.IsDryRun(fileOp) {
+		cleanStaging(cfg.BackupDir)
+	}
+
+	// 生成备份名称，格式为 WTF_Backup_YYYY-MM-DD_HH-MM-SS
 	now := time.Now()
-	backupName := fmt.Sprintf("WTF_Backup_%s", now.Format("2006-01-02_15-04-05"))
-	backupPath := filepath.Join(cfg.BackupDir, backupName)
+	backupName := snapshot.NewName(now)
+	b := snapshot.Backup{
+		Name:   backupName,
+		Path:   filepath.Join(cfg.BackupDir, backupName+format.Ext()),
+		Format: format,
+		Time:   now,
+	}
+
+	if _, err := os.Stat(b.Path); err == nil {
+		return fmt.Errorf("备份 %s 已存在", b.Path)
+	}
+
+	// 试运行时只记录将要写入的内容
+	if dryRun, ok := fileOp.(*fileutil.DryRunOperator); ok {
+		return planBackup(ctx, cfg, b, dryRun)
+	}
+
+	meta := newMeta(cfg, opts)
+	meta.GameRunning = running
+
+	// 锁文件保存在备份文件夹中，第一次备份到新的文件夹（或新添加的来源）时需要先创建
+	if err := fileOp.EnsureDir(cfg.BackupDir); err != nil {
+		return fmt.Errorf("创建备份文件夹失败: %w", err)
+	}
+
+	// 写入期间持有锁文件，避免同时运行的备份或清理删除还没有写完的临时备份和对象
+	unlock, err := snapshot.Lock(b)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// 先写入临时备份，全部复制完成并校验通过后再重命名为正式名称，
+	// 这样中途失败或被中断时不会留下看起来完整的备份
+	staging := b.Staging()
+	if err := createBackup(ctx, cfg, staging, fileOp, opts.ShowProgress); err != nil {
+		snapshot.Remove(staging)
+		return fmt.Errorf("备份过程中出错: %w", err)
+	}
+
+	if err := finalizeBackup(cfg, b, staging, meta); err != nil {
+		snapshot.Remove(staging)
+		return err
+	}
+
+	logger.With(logger.Fields{logger.FieldOp: "backup", logger.FieldBackup: b.Name, logger.FieldBytes: meta.Size}).
+		Info("备份耗时 %.1f 秒", meta.Duration)
+	summary = progress.Summary{Backup: b.Name, Files: meta.Files, Bytes: meta.Size, Duration: meta.Duration}
+	return nil
+}
+
+// warn 记录警告日志并发布 warning 事件
+func warn(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	logger.With(logger.Fields{logger.FieldOp: "backup"}).Warn("%s", msg)
+	progress.Emit(progress.Event{Type: progress.EventWarning, Op: "backup", Message: msg})
+}
+
+// newMeta 生成备份信息中在备份开始时就能确定的部分
+func newMeta(cfg config.Config, opts Options) *snapshot.Meta {
+	meta := &snapshot.Meta{
+		ToolVersion: opts.Version,
+		WtfPath:     cfg.WtfPath,
+		Label:       opts.Label,
+		Note:        opts.Note,
+		Pinned:      opts.Pin,
+	}
+	if flavor, ok := detect.FlavorOf(cfg.WtfPath); ok {
+		meta.Flavor = string(flavor)
+	}
+	if hostname, err := os.Hostname(); err == nil {
+		meta.Hostname = hostname
+	}
+	return meta
+}
+
+// checkGame 按配置处理游戏正在运行的情况，返回开始备份时游戏是否正在运行
+func checkGame(ctx context.Context, cfg config.Config, opts Options, dryRun bool) (bool, error) {
+	policy, err := process.ParsePolicy(cfg.OnGameRunning)
+	if err != nil {
+		return false, err
+	}
+	err = process.CheckNotRunning(opts.Processes)
+	if err == nil {
+		return false, nil
+	}
+
+	switch {
+	case policy == process.PolicyWarn || dryRun:
+		warn("%v，备份过程中文件可能被修改，建议退出游戏后再备份", err)
+		return true, nil
+	case policy == process.PolicyWait:
+		return false, process.Wait(ctx, opts.Processes, gameWaitInterval)
+	default:
+		return false, fmt.Errorf("%w，已取消备份 (退出游戏后再备份，或使用 -if-running warn)", err)
+	}
+}
+
+// cleanStaging 清理上次中断的备份留下的临时文件
+func cleanStaging(backupDir string) {
+	removed, err := snapshot.CleanStaging(backupDir)
+	for _, path := range removed {
+		warn("发现上次未完成的备份，已删除: %s", path)
+	}
+	if err != nil {
+		warn("清理未完成的备份失败: %v", err)
+	}
+}
+
+// createBackup 按备份格式将WTF文件夹写入 b.Path
+func createBackup(ctx context.Context, cfg config.Config, b snapshot.Backup, fileOp fileutil.FileOperator, showProgress bool) error {
+	if b.Format.IsArchive() {
+		// 将WTF文件夹以流的方式写入单个压缩包
+		if err := fileOp.EnsureDir(cfg.BackupDir); err != nil {
+			return fmt.Errorf("创建备份文件夹失败: %w", err)
+		}
+		logger.Info("开始备份WTF文件夹到压缩包: %s", b.Path)
+		if err := snapshot.CreateArchive(ctx, cfg.WtfPath, b.Path, b.Format, showProgress); err != nil {
+			os.Remove(b.Path)
+			return err
+		}
+		return nil
+	}
+
+	if b.Format == snapshot.FormatRepo {
+		// 仓库模式：文件内容按哈希只保存一份，备份本身是一份清单
+		if err := fileOp.EnsureDir(cfg.BackupDir); err != nil {
+			return fmt.Errorf("创建备份文件夹失败: %w", err)
+		}
+		logger.Info("开始备份WTF文件夹到仓库快照: %s", b.Path)
+		if err := snapshot.CreateRepo(ctx, cfg.WtfPath, b.Path, showProgress); err != nil {
+			os.Remove(b.Path)
+			return err
+		}
+		return nil
+	}
 
 	// 创建备份文件夹
-	if err := fileOp.EnsureDir(backupPath); err != nil {
+	if err := fileOp.EnsureDir(b.Path); err != nil {
 		return fmt.Errorf("创建备份文件夹失败: %w", err)
 	}
 
+	// 增量备份：与上一个文件夹备份相比未变化的文件使用硬链接
+	if cfg.Incremental {
+		if prev, ok := previousDirBackup(cfg.BackupDir, b.Path); ok {
+			logger.Info("开始增量备份WTF文件夹到: %s (基于 %s)", b.Path, prev.Name)
+			return copyIncremental(ctx, cfg.WtfPath, b.Path, prev.Path, fileOp, showProgress)
+		}
+		logger.Info("没有可用于增量备份的文件夹备份，将进行完整备份")
+	}
+
 	// 开始复制文件
-	logger.Info("开始备份WTF文件夹到: %s", backupPath)
-	err = fileOp.CopyDir(cfg.WtfPath, backupPath, showProgress)
+	logger.Info("开始备份WTF文件夹到: %s", b.Path)
+	return fileOp.CopyDir(ctx, cfg.WtfPath, b.Path, showProgress)
+}
+
+// finalizeBackup 校验临时备份并生成清单和备份信息，全部通过后将其重命名为正式备份
+func finalizeBackup(cfg config.Config, b, staging snapshot.Backup, meta *snapshot.Meta) error {
+	m, err := buildManifest(staging)
 	if err != nil {
-		return fmt.Errorf("备份过程中出错: %w", err)
+		return fmt.Errorf("生成备份清单失败: %w", err)
 	}
 
+	mismatches, err := checkAgainstSource(cfg.WtfPath, m)
+	if err != nil {
+		return fmt.Errorf("备份校验失败: %w", err)
+	}
+	if len(mismatches) > 0 {
+		// 游戏运行时按 warn 继续备份，游戏在复制过程中写入的文件必然与备份不一致，
+		// 这时只标记备份，不让备份失败
+		if !meta.GameRunning {
+			for _, mismatch := range mismatches {
+				logger.Error("%s", mismatch)
+				progress.Emit(progress.Event{Type: progress.EventError, Op: "backup", Path: mismatch.Path, Message: mismatch.Message})
+			}
+			return fmt.Errorf("备份校验失败: %d 个文件缺失或与备份不一致", len(mismatches))
+		}
+		for _, mismatch := range mismatches {
+			warn("%s (游戏正在运行，文件可能在备份过程中被修改)", mismatch)
+			meta.Problems = append(meta.Problems, mismatch.String())
+		}
+		meta.Suspect = true
+	}
+
+	// 检查 SavedVariables 是否为空、损坏或被截断，有问题的备份仍然保留但会被标记
+	problems, err := validateBackup(staging, m, previousManifest(cfg.BackupDir))
+	if err != nil {
+		return fmt.Errorf("检查SavedVariables失败: %w", err)
+	}
+	if len(problems) > 0 {
+		for _, problem := range problems {
+			warn("可能损坏的SavedVariables: %s", problem)
+		}
+		meta.Suspect = true
+		meta.Problems = append(meta.Problems, problems...)
+	}
+	if meta.Suspect {
+		warn("备份已标记为可疑，清理旧备份时不会计入保留数量")
+	}
+	meta.Files = len(m.Files)
+	meta.Size = m.TotalSize()
+	meta.Duration = time.Since(b.Time).Seconds()
+	if err := snapshot.SaveMeta(b, meta); err != nil {
+		return err
+	}
+
+	// 仓库快照本身就是清单，其他格式单独保存清单
+	if b.Format != snapshot.FormatRepo {
+		if err := m.Save(b.ManifestPath()); err != nil {
+			return fmt.Errorf("保存备份清单失败: %w", err)
+		}
+	}
+	logger.Info("已生成备份清单: %d 个文件, %s", len(m.Files), progress.FormatBytes(m.TotalSize()))
+
+	if err := os.Rename(staging.Path, b.Path); err != nil {
+		if b.Format != snapshot.FormatRepo {
+			os.Remove(b.ManifestPath())
+		}
+		os.Remove(b.MetaPath())
+		return fmt.Errorf("保存备份失败: %w", err)
+	}
 	return nil
 }
 
+// buildManifest 重新读取刚写入的备份，生成备份清单
+func buildManifest(b snapshot.Backup) (*manifest.Manifest, error) {
+	if b.Format == snapshot.FormatRepo {
+		return manifest.Load(b.Path)
+	}
+
+	src, err := snapshot.Open(b.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	return manifest.Build(src)
+}
+
+// sourceMismatch WTF文件夹中没有完整写入备份的文件
+type sourceMismatch struct {
+	Path    string
+	Message string
+}
+
+func (m sourceMismatch) String() string {
+	return m.Message + ": " + m.Path
+}
+
+// checkAgainstSource 检查WTF文件夹中的每个文件都已完整写入备份，
+// 比较文件大小和 SHA-256，返回备份过程中被修改或写入不完整的文件
+func checkAgainstSource(src string, m *manifest.Manifest) ([]sourceMismatch, error) {
+	index := m.Index()
+	var mismatches []sourceMismatch
+	err := filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		relPath = filepath.ToSlash(relPath)
+
+		entry, ok := index[relPath]
+		if !ok {
+			mismatches = append(mismatches, sourceMismatch{relPath, "备份中缺少文件"})
+		} else if entry.Size != info.Size() {
+			mismatches = append(mismatches, sourceMismatch{relPath,
+				fmt.Sprintf("备份中的文件大小不一致 (%d != %d)", entry.Size, info.Size())})
+		} else {
+			// 大小相同时比较内容，写入过程中被截断或改写的文件大小不一定变化
+			sum, err := store.HashFile(path)
+			if err != nil {
+				return fmt.Errorf("计算文件 %s 的校验和失败: %w", relPath, err)
+			}
+			if sum != entry.SHA256 {
+				mismatches = append(mismatches, sourceMismatch{relPath, "备份中的文件内容不一致"})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return mismatches, nil
+}
+
 // copyDir 递归复制文件夹内容
 func copyDir(src, dst string) error {
 	// 获取源文件夹信息
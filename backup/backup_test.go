@@ -0,0 +1,209 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/lizhening/WtfBackup/config"
+	"github.com/lizhening/WtfBackup/pkg/fileutil"
+	"github.com/lizhening/WtfBackup/pkg/manifest"
+	"github.com/lizhening/WtfBackup/pkg/process"
+	"github.com/lizhening/WtfBackup/pkg/snapshot"
+)
+
+// fakeLister 返回固定的进程列表
+type fakeLister []string
+
+func (l fakeLister) List() ([]string, error) {
+	return l, nil
+}
+
+func TestCheckGame(t *testing.T) {
+	running := Options{Processes: fakeLister{"bash", "Wow.exe"}}
+	stopped := Options{Processes: fakeLister{"bash"}}
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name        string
+		ctx         context.Context
+		policy      string
+		opts        Options
+		dryRun      bool
+		wantRunning bool
+		wantErr     error
+	}{
+		{"没有运行", context.Background(), "abort", stopped, false, false, nil},
+		{"警告后继续", context.Background(), "warn", running, false, true, nil},
+		{"默认警告", context.Background(), "", running, false, true, nil},
+		{"等待时被取消", cancelled, "wait", running, false, false, context.Canceled},
+		{"取消备份", context.Background(), "abort", running, false, false, process.ErrGameRunning},
+		{"试运行时只警告", context.Background(), "abort", running, true, true, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Config{OnGameRunning: tt.policy}
+			got, err := checkGame(tt.ctx, cfg, tt.opts, tt.dryRun)
+			if got != tt.wantRunning {
+				t.Errorf("返回游戏正在运行 = %v，期望 %v", got, tt.wantRunning)
+			}
+			if tt.wantErr == nil && err != nil {
+				t.Errorf("返回了错误 %v", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("返回 %v，期望 %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if _, err := checkGame(context.Background(), config.Config{OnGameRunning: "ignore"}, running, false); err == nil {
+		t.Errorf("无效的处理方式应返回错误")
+	}
+}
+
+func TestCheckAgainstSource(t *testing.T) {
+	src := t.TempDir()
+	modTime := time.Date(2024, 5, 1, 20, 0, 0, 0, time.Local)
+	writeTestFile(t, filepath.Join(src, "Account", "ACC", "SavedVariables", "Addon.lua"), "A = 1", modTime)
+	writeTestFile(t, filepath.Join(src, "Config.wtf"), "SET a 1", modTime)
+
+	m, err := manifest.Build(os.DirFS(src))
+	if err != nil {
+		t.Fatalf("生成清单失败: %v", err)
+	}
+	if mismatches, err := checkAgainstSource(src, m); err != nil || len(mismatches) > 0 {
+		t.Fatalf("未修改的文件校验失败: %v, %v", mismatches, err)
+	}
+
+	// 大小不变但内容被修改
+	writeTestFile(t, filepath.Join(src, "Config.wtf"), "SET a 2", modTime)
+	writeTestFile(t, filepath.Join(src, "new.lua"), "", modTime)
+	mismatches, err := checkAgainstSource(src, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []sourceMismatch{
+		{"Config.wtf", "备份中的文件内容不一致"},
+		{"new.lua", "备份中缺少文件"},
+	}
+	if !reflect.DeepEqual(mismatches, want) {
+		t.Errorf("校验结果为 %v，期望 %v", mismatches, want)
+	}
+}
+
+func TestFinalizeBackupSourceChanged(t *testing.T) {
+	for _, gameRunning := range []bool{false, true} {
+		t.Run(fmt.Sprintf("游戏正在运行=%v", gameRunning), func(t *testing.T) {
+			cfg := config.Config{WtfPath: newTestWtf(t), BackupDir: t.TempDir()}
+			now := time.Now()
+			b := snapshot.Backup{Name: snapshot.NewName(now), Format: snapshot.FormatDir, Time: now}
+			b.Path = filepath.Join(cfg.BackupDir, b.Name)
+			staging := b.Staging()
+			fileOp := fileutil.NewDefaultFileOperator(32*1024, 1)
+			if err := fileOp.CopyDir(context.Background(), cfg.WtfPath, staging.Path, false); err != nil {
+				t.Fatal(err)
+			}
+
+			// 复制完成后游戏写入了 SavedVariables
+			writeTestFile(t, filepath.Join(cfg.WtfPath, "Account", "ACC", "SavedVariables", "Addon.lua"), "AddonDB = {\n\tx = 1,\n}\n", now)
+
+			meta := &snapshot.Meta{GameRunning: gameRunning}
+			err := finalizeBackup(cfg, b, staging, meta)
+			if !gameRunning {
+				if err == nil {
+					t.Fatalf("游戏没有运行时文件不一致应导致备份失败")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("游戏运行时文件不一致不应导致备份失败: %v", err)
+			}
+			if !meta.Suspect {
+				t.Errorf("备份应标记为可疑")
+			}
+			want := "备份中的文件大小不一致 (14 != 22): Account/ACC/SavedVariables/Addon.lua"
+			if len(meta.Problems) != 1 || meta.Problems[0] != want {
+				t.Errorf("备份信息中的问题为 %q，期望 %q", meta.Problems, want)
+			}
+			if _, err := os.Stat(b.Path); err != nil {
+				t.Errorf("备份没有保存: %v", err)
+			}
+		})
+	}
+}
+
+// newTestWtf 创建一个小的WTF文件夹
+func newTestWtf(t *testing.T) string {
+	t.Helper()
+	wtfPath := filepath.Join(t.TempDir(), "WTF")
+	modTime := time.Date(2024, 5, 1, 20, 0, 0, 0, time.Local)
+	writeTestFile(t, filepath.Join(wtfPath, "Config.wtf"), "SET a 1", modTime)
+	writeTestFile(t, filepath.Join(wtfPath, "Account", "ACC", "SavedVariables", "Addon.lua"), "AddonDB = {\n}\n", modTime)
+	return wtfPath
+}
+
+func TestBackupWtfCreatesBackupDir(t *testing.T) {
+	for _, format := range []string{"dir", "zip", "repo"} {
+		t.Run(format, func(t *testing.T) {
+			backupDir := filepath.Join(t.TempDir(), "fresh", "new")
+			cfg := config.Config{WtfPath: newTestWtf(t), BackupDir: backupDir, Format: format}
+			fileOp := fileutil.NewDefaultFileOperator(32*1024, 1)
+			if err := BackupWtf(context.Background(), cfg, fileOp, Options{Processes: fakeLister{}}); err != nil {
+				t.Fatalf("备份到不存在的文件夹失败: %v", err)
+			}
+
+			backups, err := snapshot.List(backupDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(backups) != 1 {
+				t.Fatalf("找到 %d 个备份，期望 1 个", len(backups))
+			}
+			if locks, _ := filepath.Glob(filepath.Join(backupDir, "*.lock.json")); len(locks) > 0 {
+				t.Errorf("备份完成后仍有锁文件: %v", locks)
+			}
+		})
+	}
+}
+
+func TestBackupWtfDryRunDoesNotCreateBackupDir(t *testing.T) {
+	backupDir := filepath.Join(t.TempDir(), "new")
+	cfg := config.Config{WtfPath: newTestWtf(t), BackupDir: backupDir}
+	fileOp := fileutil.NewDryRunOperator(fileutil.NewDefaultFileOperator(32*1024, 1))
+	if err := BackupWtf(context.Background(), cfg, fileOp, Options{Processes: fakeLister{}}); err != nil {
+		t.Fatalf("试运行失败: %v", err)
+	}
+	if _, err := os.Stat(backupDir); !os.IsNotExist(err) {
+		t.Errorf("试运行不应创建备份文件夹")
+	}
+}
+
+func TestBackupWtfNewSource(t *testing.T) {
+	backupDir := t.TempDir()
+	cfg := config.Config{
+		BackupDir: backupDir,
+		Sources:   []config.Source{{Name: "classic", WtfPath: newTestWtf(t)}},
+	}
+	sourceCfg, err := cfg.ForSource("classic")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fileOp := fileutil.NewDefaultFileOperator(32*1024, 1)
+	if err := BackupWtf(context.Background(), sourceCfg, fileOp, Options{Processes: fakeLister{}}); err != nil {
+		t.Fatalf("第一次备份新添加的来源失败: %v", err)
+	}
+	backups, err := snapshot.List(filepath.Join(backupDir, "classic"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 1 {
+		t.Errorf("来源的备份文件夹中有 %d 个备份，期望 1 个", len(backups))
+	}
+}
@@ -0,0 +1,73 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/lizhening/WtfBackup/config"
+	"github.com/lizhening/WtfBackup/pkg/fileutil"
+	"github.com/lizhening/WtfBackup/pkg/snapshot"
+	"github.com/lizhening/WtfBackup/pkg/store"
+)
+
+// planBackup 试运行：记录备份将写入的内容而不修改磁盘
+func planBackup(ctx context.Context, cfg config.Config, b snapshot.Backup, dryRun *fileutil.DryRunOperator) error {
+	switch {
+	case b.Format == snapshot.FormatRepo:
+		return planRepo(cfg, b, dryRun)
+
+	case b.Format.IsArchive():
+		// 压缩包不经过文件操作器写入，只记录压缩前的总大小
+		size, err := dryRun.GetDirSize(cfg.WtfPath)
+		if err != nil {
+			return fmt.Errorf("计算WTF文件夹大小失败: %w", err)
+		}
+		dryRun.Record(fileutil.Op{Kind: fileutil.OpCreate, Path: b.Path, Source: cfg.WtfPath, Size: size, Reason: "压缩前的大小"})
+		return nil
+
+	default:
+		// 文件夹格式与正式备份走相同的复制流程
+		return createBackup(ctx, cfg, b, dryRun, false)
+	}
+}
+
+// planRepo 记录仓库模式下需要新增的对象，已存在的对象会被跳过
+func planRepo(cfg config.Config, b snapshot.Backup, dryRun *fileutil.DryRunOperator) error {
+	objects := snapshot.ObjectStore(cfg.BackupDir)
+	planned := make(map[string]bool)
+
+	err := dryRun.Walk(cfg.WtfPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		hash, err := store.HashFile(path)
+		if err != nil {
+			return fmt.Errorf("计算文件哈希失败 %s: %w", path, err)
+		}
+		relPath, err := filepath.Rel(cfg.WtfPath, path)
+		if err != nil {
+			return fmt.Errorf("计算相对路径失败: %w", err)
+		}
+
+		op := fileutil.Op{Kind: fileutil.OpCreate, Path: objects.Path(hash), Source: relPath, Size: info.Size()}
+		if objects.Has(hash) || planned[hash] {
+			op.Kind = fileutil.OpSkip
+			op.Reason = "对象已存在: " + filepath.ToSlash(relPath)
+		}
+		planned[hash] = true
+		dryRun.Record(op)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	dryRun.Record(fileutil.Op{Kind: fileutil.OpCreate, Path: b.Path, Reason: "快照清单"})
+	return nil
+}
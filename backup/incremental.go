@@ -0,0 +1,106 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/lizhening/WtfBackup/pkg/fileutil"
+	"github.com/lizhening/WtfBackup/pkg/logger"
+	"github.com/lizhening/WtfBackup/pkg/progress"
+	"github.com/lizhening/WtfBackup/pkg/snapshot"
+)
+
+// previousDirBackup 返回备份目录中除 current 以外最新的文件夹格式备份
+func previousDirBackup(backupDir, current string) (snapshot.Backup, bool) {
+	backups, err := snapshot.List(backupDir)
+	if err != nil {
+		return snapshot.Backup{}, false
+	}
+	for _, b := range backups {
+		if b.Format == snapshot.FormatDir && b.Path != current {
+			return b, true
+		}
+	}
+	return snapshot.Backup{}, false
+}
+
+// copyIncremental 以增量方式备份 src 到 dst
+// 与上一个备份 prev 相比未变化的文件创建硬链接，其余文件正常复制，
+// 因此每个备份仍然是完整的文件夹，但只有变化的文件会占用新的磁盘空间
+func copyIncremental(ctx context.Context, src, dst, prev string, fileOp fileutil.FileOperator, showProgress bool) error {
+	var linked, copied int
+	var copiedBytes int64
+
+	var bar *progress.ProgressBar
+	if showProgress {
+		var err error
+		bar, err = fileutil.NewDirProgress(fileOp, src, "增量备份", filepath.Base(dst))
+		if err != nil {
+			return err
+		}
+	}
+
+	err := fileOp.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		// 计算相对路径
+		relPath, err := filepath.Rel(src, path)
+		if err != nil {
+			return fmt.Errorf("计算相对路径失败: %w", err)
+		}
+		dstPath := filepath.Join(dst, relPath)
+
+		if info.IsDir() {
+			return fileOp.EnsureDir(dstPath)
+		}
+
+		prevPath := filepath.Join(prev, relPath)
+		if unchanged(info, prevPath) {
+			if err := fileOp.Link(ctx, prevPath, dstPath); err != nil {
+				return fmt.Errorf("链接文件 %s 失败: %w", relPath, err)
+			}
+			linked++
+		} else {
+			if err := fileOp.Copy(ctx, path, dstPath); err != nil {
+				return fmt.Errorf("复制文件 %s 失败: %w", relPath, err)
+			}
+			copied++
+			copiedBytes += info.Size()
+		}
+
+		if bar != nil {
+			bar.Update(info.Size())
+			bar.FileDone(filepath.ToSlash(relPath))
+		}
+		return nil
+	})
+	if bar != nil {
+		if err != nil {
+			bar.Stop()
+		} else {
+			bar.Finish()
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	logger.Info("增量备份完成: 链接未变化的文件 %d 个，复制文件 %d 个 (%s)", linked, copied, progress.FormatBytes(copiedBytes))
+	return nil
+}
+
+// unchanged 判断文件与上一个备份中的副本是否相同
+// 只有大小和修改时间都相同时才视为未变化。硬链接与上一个备份共享修改时间，
+// 内容相同但修改时间不同的文件（例如游戏退出时重写的SavedVariables）需要复制，
+// 否则备份中记录的修改时间是旧的
+func unchanged(info os.FileInfo, prevPath string) bool {
+	prevInfo, err := os.Stat(prevPath)
+	if err != nil || !prevInfo.Mode().IsRegular() {
+		return false
+	}
+	return prevInfo.Size() == info.Size() && prevInfo.ModTime().Equal(info.ModTime())
+}
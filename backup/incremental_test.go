@@ -0,0 +1,82 @@
+package backup
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lizhening/WtfBackup/pkg/fileutil"
+)
+
+func TestCopyIncremental(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "WTF")
+	prev := filepath.Join(dir, "prev")
+	dst := filepath.Join(dir, "dst")
+
+	old := time.Date(2024, 5, 1, 20, 0, 0, 0, time.Local)
+	now := old.Add(time.Hour)
+	files := []struct {
+		name        string
+		content     string
+		prevContent string
+		modTime     time.Time
+		prevModTime time.Time
+		wantLinked  bool
+	}{
+		{"same.lua", "A = 1", "A = 1", old, old, true},
+		{"touched.lua", "A = 1", "A = 1", now, old, false},
+		{"changed.lua", "A = 22", "A = 1", old, old, false},
+		{"new.lua", "A = 1", "", now, time.Time{}, false},
+	}
+	for _, f := range files {
+		writeTestFile(t, filepath.Join(src, f.name), f.content, f.modTime)
+		if f.prevContent != "" {
+			writeTestFile(t, filepath.Join(prev, f.name), f.prevContent, f.prevModTime)
+		}
+	}
+
+	fileOp := fileutil.NewDefaultFileOperator(32*1024, 1)
+	if err := copyIncremental(context.Background(), src, dst, prev, fileOp, false); err != nil {
+		t.Fatalf("增量备份失败: %v", err)
+	}
+
+	for _, f := range files {
+		path := filepath.Join(dst, f.name)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("读取 %s 失败: %v", f.name, err)
+		}
+		if string(data) != f.content {
+			t.Errorf("%s 的内容为 %q，期望 %q", f.name, data, f.content)
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !info.ModTime().Equal(f.modTime) {
+			t.Errorf("%s 的修改时间为 %v，期望 %v", f.name, info.ModTime(), f.modTime)
+		}
+		prevInfo, err := os.Stat(filepath.Join(prev, f.name))
+		linked := err == nil && os.SameFile(info, prevInfo)
+		if linked != f.wantLinked {
+			t.Errorf("%s 是否为硬链接: %v，期望 %v", f.name, linked, f.wantLinked)
+		}
+	}
+}
+
+// writeTestFile 写入文件并设置修改时间
+func writeTestFile(t *testing.T, path, content string, modTime time.Time) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,73 @@
+package backup
+
+import (
+	"io/fs"
+
+	"github.com/lizhening/WtfBackup/pkg/snapshot"
+	"github.com/lizhening/WtfBackup/pkg/wtf"
+)
+
+// Summary 备份概要信息
+type Summary struct {
+	snapshot.Backup
+	// 备份中的文件数量
+	Files int
+	// 备份中所有文件的总大小（未压缩）
+	Size int64
+	// 备份中包含的已配置插件
+	Addons []string
+	// 备份的附加信息
+	Meta *snapshot.Meta
+}
+
+// Summarize 统计备份中的文件数量、总大小以及包含哪些插件的配置
+func Summarize(b snapshot.Backup, addons []string) (Summary, error) {
+	summary := Summary{Backup: b}
+
+	meta, err := snapshot.LoadMeta(b)
+	if err != nil {
+		return summary, err
+	}
+	summary.Meta = meta
+
+	src, err := snapshot.Open(b.Path)
+	if err != nil {
+		return summary, err
+	}
+	defer src.Close()
+
+	found := make(map[string]bool)
+	err = fs.WalkDir(src, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		summary.Files++
+		summary.Size += info.Size()
+
+		for _, addon := range addons {
+			if !found[addon] && wtf.IsAddonFile(path, addon) {
+				found[addon] = true
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return summary, err
+	}
+
+	// 按配置中的顺序输出
+	for _, addon := range addons {
+		if found[addon] {
+			summary.Addons = append(summary.Addons, addon)
+		}
+	}
+	return summary, nil
+}
@@ -0,0 +1,31 @@
+package backup
+
+import (
+	"github.com/lizhening/WtfBackup/pkg/snapshot"
+)
+
+// Pin 固定备份，清理旧备份时不会删除。note 不为空时同时更新备注
+func Pin(b snapshot.Backup, note string) error {
+	meta, err := snapshot.LoadMeta(b)
+	if err != nil {
+		return err
+	}
+	meta.Pinned = true
+	if note != "" {
+		meta.Note = note
+	}
+	return snapshot.SaveMeta(b, meta)
+}
+
+// Unpin 取消固定备份，备注保持不变
+func Unpin(b snapshot.Backup) error {
+	meta, err := snapshot.LoadMeta(b)
+	if err != nil {
+		return err
+	}
+	if !meta.Pinned {
+		return nil
+	}
+	meta.Pinned = false
+	return snapshot.SaveMeta(b, meta)
+}
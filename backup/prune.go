@@ -0,0 +1,102 @@
+package backup
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/lizhening/WtfBackup/pkg/fileutil"
+	"github.com/lizhening/WtfBackup/pkg/logger"
+	"github.com/lizhening/WtfBackup/pkg/progress"
+	"github.com/lizhening/WtfBackup/pkg/retention"
+	"github.com/lizhening/WtfBackup/pkg/snapshot"
+)
+
+// Prune 按保留策略清理旧备份，每删除一个备份发布一个 remove 事件。
+// 备份和附属文件通过 fileOp 删除，试运行时只记录将被删除的文件，不发布事件，也不清理对象存储
+func Prune(backupDir string, policy retention.Policy, fileOp fileutil.FileOperator) (err error) {
+	dryRun := fileutil.IsDryRun(fileOp)
+	start := time.Now()
+	var summary progress.Summary
+	if !dryRun {
+		progress.Emit(progress.Event{Type: progress.EventStart, Op: "prune", Path: backupDir, Message: policy.String()})
+		defer func() {
+			summary.Duration = time.Since(start).Seconds()
+			progress.Emit(progress.Done("prune", summary, err))
+		}()
+	}
+
+	expired, err := expiredBackups(backupDir, policy)
+	if err != nil {
+		return err
+	}
+	if len(expired) == 0 {
+		return nil
+	}
+
+	// 删除旧备份（文件夹、压缩包或仓库快照）
+	for _, d := range expired {
+		size, _ := fileOp.GetDirSize(d.Backup.Path)
+		log := logger.With(logger.Fields{logger.FieldOp: "prune", logger.FieldBackup: d.Backup.Name, logger.FieldBytes: size})
+		if dryRun {
+			log.Info("将删除旧备份: %s (%s)", d.Backup.Path, d.Reason)
+		} else {
+			log.Info("删除旧备份: %s (%s)", d.Backup.Path, d.Reason)
+		}
+		if err := removeBackup(d.Backup, fileOp); err != nil {
+			log.Error("删除备份失败 %s: %v", d.Backup.Path, err)
+			if !dryRun {
+				progress.Emit(progress.Event{Type: progress.EventError, Op: "prune", Path: d.Backup.Path, Message: err.Error()})
+			}
+			continue
+		}
+		if dryRun {
+			continue
+		}
+		summary.Removed++
+		summary.Bytes += size
+		progress.Emit(progress.Event{Type: progress.EventRemove, Op: "prune", Path: d.Backup.Path, Size: size, Message: d.Reason})
+	}
+	if dryRun {
+		return nil
+	}
+
+	// 清理不再被任何仓库快照引用的对象
+	removed, freed, err := snapshot.CollectGarbage(backupDir)
+	if err != nil {
+		return fmt.Errorf("清理对象存储失败: %w", err)
+	}
+	if removed > 0 {
+		logger.Info("已清理 %d 个未引用的对象，释放 %s", removed, progress.FormatBytes(freed))
+	}
+	return nil
+}
+
+// removeBackup 通过 fileOp 删除备份及其所有附属文件
+func removeBackup(b snapshot.Backup, fileOp fileutil.FileOperator) error {
+	paths, err := snapshot.Files(b)
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		if err := fileOp.Remove(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// expiredBackups 返回按保留策略将被删除的备份
+func expiredBackups(backupDir string, policy retention.Policy) ([]retention.Decision, error) {
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+	// 没有任何保留规则时不删除备份
+	if policy.IsZero() {
+		return nil, nil
+	}
+	backups, err := snapshot.List(backupDir)
+	if err != nil {
+		return nil, err
+	}
+	return retention.Expired(backups, policy, time.Now()), nil
+}
@@ -0,0 +1,83 @@
+package backup
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/lizhening/WtfBackup/pkg/fileutil"
+	"github.com/lizhening/WtfBackup/pkg/retention"
+	"github.com/lizhening/WtfBackup/pkg/snapshot"
+)
+
+// makeBackups 创建 n 个相隔一天的文件夹备份，每个备份带有备份信息，按时间倒序返回
+func makeBackups(t *testing.T, backupDir string, n int) []snapshot.Backup {
+	t.Helper()
+	now := time.Now().Truncate(time.Second)
+	var backups []snapshot.Backup
+	for i := 0; i < n; i++ {
+		at := now.AddDate(0, 0, -i)
+		b := snapshot.Backup{Name: snapshot.NewName(at), Format: snapshot.FormatDir, Time: at}
+		b.Path = filepath.Join(backupDir, b.Name)
+		writeTestFile(t, filepath.Join(b.Path, "Config.wtf"), "SET a 1", at)
+		if err := snapshot.SaveMeta(b, &snapshot.Meta{}); err != nil {
+			t.Fatal(err)
+		}
+		backups = append(backups, b)
+	}
+	return backups
+}
+
+func TestPrune(t *testing.T) {
+	backupDir := t.TempDir()
+	backups := makeBackups(t, backupDir, 3)
+	policy := retention.Policy{Last: 1}
+
+	// 试运行只记录将被删除的备份和附属文件
+	dryRun := fileutil.NewDryRunOperator(fileutil.NewDefaultFileOperator(32*1024, 1))
+	if err := Prune(backupDir, policy, dryRun); err != nil {
+		t.Fatalf("试运行清理失败: %v", err)
+	}
+	var removed []string
+	for _, op := range dryRun.Ops() {
+		if op.Kind != fileutil.OpRemove {
+			t.Errorf("试运行记录了 %s 操作", op.Kind)
+		}
+		removed = append(removed, op.Path)
+	}
+	var want []string
+	for _, b := range backups[1:] {
+		want = append(want, b.Path, b.MetaPath())
+	}
+	sort.Strings(removed)
+	sort.Strings(want)
+	if len(removed) != len(want) {
+		t.Fatalf("试运行记录删除 %v，期望 %v", removed, want)
+	}
+	for i := range want {
+		if removed[i] != want[i] {
+			t.Fatalf("试运行记录删除 %v，期望 %v", removed, want)
+		}
+	}
+	if left, _ := snapshot.List(backupDir); len(left) != 3 {
+		t.Fatalf("试运行删除了备份，剩余 %d 个", len(left))
+	}
+
+	if err := Prune(backupDir, policy, fileutil.NewDefaultFileOperator(32*1024, 1)); err != nil {
+		t.Fatalf("清理失败: %v", err)
+	}
+	left, err := snapshot.List(backupDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(left) != 1 || left[0].Name != backups[0].Name {
+		t.Errorf("清理后剩余 %v，期望只保留最新的备份", left)
+	}
+	for _, path := range want {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s 没有被删除", path)
+		}
+	}
+}
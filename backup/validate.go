@@ -0,0 +1,62 @@
+package backup
+
+import (
+	"fmt"
+	"io/fs"
+
+	"github.com/lizhening/WtfBackup/pkg/logger"
+	"github.com/lizhening/WtfBackup/pkg/manifest"
+	"github.com/lizhening/WtfBackup/pkg/snapshot"
+	"github.com/lizhening/WtfBackup/pkg/wtf"
+)
+
+// previousManifest 返回备份目录中最新的备份的清单，没有时返回 nil
+func previousManifest(backupDir string) *manifest.Manifest {
+	backups, err := snapshot.List(backupDir)
+	if err != nil || len(backups) == 0 {
+		return nil
+	}
+	m, err := manifest.Load(backups[0].ManifestPath())
+	if err != nil {
+		logger.Debug("读取上一个备份的清单失败: %v", err)
+		return nil
+	}
+	return m
+}
+
+// validateBackup 检查备份中的每个 SavedVariables 文件：不能为空、必须能被解析，
+// 并且与上一个备份相比没有明显变小。返回发现的问题
+func validateBackup(b snapshot.Backup, m, previous *manifest.Manifest) ([]string, error) {
+	src, err := snapshot.Open(b.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	var previousFiles map[string]manifest.Entry
+	if previous != nil {
+		previousFiles = previous.Index()
+	}
+
+	var problems []string
+	for _, entry := range m.Files {
+		if !wtf.IsSavedVariablesFile(entry.Path) {
+			continue
+		}
+
+		data, err := fs.ReadFile(src, entry.Path)
+		if err != nil {
+			return nil, fmt.Errorf("读取 %s 失败: %w", entry.Path, err)
+		}
+		if err := wtf.CheckSavedVariables(data); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", entry.Path, err))
+			continue
+		}
+		if prev, ok := previousFiles[entry.Path]; ok {
+			if err := wtf.CheckShrink(entry.Size, prev.Size); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %v", entry.Path, err))
+			}
+		}
+	}
+	return problems, nil
+}
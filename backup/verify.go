@@ -0,0 +1,72 @@
+package backup
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+
+	"github.com/lizhening/WtfBackup/pkg/logger"
+	"github.com/lizhening/WtfBackup/pkg/manifest"
+	"github.com/lizhening/WtfBackup/pkg/snapshot"
+)
+
+// ErrNoManifest 备份没有清单（例如在启用清单之前创建的备份）
+var ErrNoManifest = errors.New("备份没有清单")
+
+// VerifyBackup 按备份清单重新计算备份中每个文件的哈希，报告缺失、多余和损坏的文件
+func VerifyBackup(b snapshot.Backup) (*manifest.Report, error) {
+	m, err := manifest.Load(b.ManifestPath())
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, ErrNoManifest
+		}
+		return nil, err
+	}
+
+	src, err := snapshot.Open(b.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	return manifest.Verify(src, m)
+}
+
+// VerifyBackups 校验多个备份并输出每个备份的结果，有备份校验失败时返回错误。
+// skipNoManifest 为 true 时跳过没有清单的备份
+func VerifyBackups(backups []snapshot.Backup, skipNoManifest bool) error {
+	failed := 0
+	for _, b := range backups {
+		report, err := VerifyBackup(b)
+		if errors.Is(err, ErrNoManifest) && skipNoManifest {
+			logger.Warn("备份 %s 没有清单，跳过校验", b.Name)
+			continue
+		}
+		if err != nil {
+			logger.Error("校验备份 %s 失败: %v", b.Name, err)
+			failed++
+			continue
+		}
+		if report.OK() {
+			logger.Info("备份 %s 校验通过 (%d 个文件)", b.Name, report.Verified)
+			continue
+		}
+
+		failed++
+		logger.Error("备份 %s 校验失败: 缺失 %d 个, 多余 %d 个, 损坏 %d 个文件",
+			b.Name, len(report.Missing), len(report.Extra), len(report.Corrupted))
+		for _, path := range report.Missing {
+			logger.Error("  缺失: %s", path)
+		}
+		for _, path := range report.Extra {
+			logger.Error("  多余: %s", path)
+		}
+		for _, path := range report.Corrupted {
+			logger.Error("  损坏: %s", path)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d 个备份校验失败", failed)
+	}
+	return nil
+}
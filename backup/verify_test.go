@@ -0,0 +1,133 @@
+package backup
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/lizhening/WtfBackup/config"
+	"github.com/lizhening/WtfBackup/pkg/fileutil"
+	"github.com/lizhening/WtfBackup/pkg/snapshot"
+)
+
+// tamperBackup 用修改后的WTF文件夹替换备份的内容，备份清单保持不变
+func tamperBackup(t *testing.T, b snapshot.Backup, wtfPath string, tamper func(wtfPath string)) {
+	t.Helper()
+	tampered := filepath.Join(t.TempDir(), "WTF")
+	fileOp := fileutil.NewDefaultFileOperator(32*1024, 1)
+	if err := fileOp.CopyDir(context.Background(), wtfPath, tampered, false); err != nil {
+		t.Fatal(err)
+	}
+	tamper(tampered)
+
+	if err := os.RemoveAll(b.Path); err != nil {
+		t.Fatal(err)
+	}
+	var err error
+	if b.Format.IsArchive() {
+		err = snapshot.CreateArchive(context.Background(), tampered, b.Path, b.Format, false)
+	} else {
+		err = fileOp.CopyDir(context.Background(), tampered, b.Path, false)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVerifyBackup(t *testing.T) {
+	addonPath := filepath.Join("Account", "ACC", "SavedVariables", "Addon.lua")
+	modTime := time.Date(2024, 5, 1, 20, 0, 0, 0, time.Local)
+
+	tests := []struct {
+		name          string
+		tamper        func(wtfPath string)
+		wantMissing   []string
+		wantExtra     []string
+		wantCorrupted []string
+	}{
+		{"未修改", func(string) {}, nil, nil, nil},
+		{
+			"缺失文件",
+			func(wtfPath string) { os.Remove(filepath.Join(wtfPath, "Config.wtf")) },
+			[]string{"Config.wtf"}, nil, nil,
+		},
+		{
+			"多余文件",
+			func(wtfPath string) { writeTestFile(t, filepath.Join(wtfPath, "extra.txt"), "x", modTime) },
+			nil, []string{"extra.txt"}, nil,
+		},
+		{
+			"内容损坏",
+			func(wtfPath string) {
+				writeTestFile(t, filepath.Join(wtfPath, addonPath), "AddonDB = {\n}\r", modTime)
+			},
+			nil, nil, []string{"Account/ACC/SavedVariables/Addon.lua"},
+		},
+	}
+
+	for _, format := range []string{"dir", "zip"} {
+		for _, tt := range tests {
+			t.Run(format+"/"+tt.name, func(t *testing.T) {
+				cfg := config.Config{WtfPath: newTestWtf(t), BackupDir: t.TempDir(), Format: format}
+				fileOp := fileutil.NewDefaultFileOperator(32*1024, 1)
+				if err := BackupWtf(context.Background(), cfg, fileOp, Options{Processes: fakeLister{}}); err != nil {
+					t.Fatalf("备份失败: %v", err)
+				}
+				backups, err := snapshot.List(cfg.BackupDir)
+				if err != nil || len(backups) != 1 {
+					t.Fatalf("找到 %d 个备份: %v", len(backups), err)
+				}
+				b := backups[0]
+				tamperBackup(t, b, cfg.WtfPath, tt.tamper)
+
+				report, err := VerifyBackup(b)
+				if err != nil {
+					t.Fatalf("校验失败: %v", err)
+				}
+				if !equalPaths(report.Missing, tt.wantMissing) {
+					t.Errorf("缺失的文件为 %v，期望 %v", report.Missing, tt.wantMissing)
+				}
+				if !equalPaths(report.Extra, tt.wantExtra) {
+					t.Errorf("多余的文件为 %v，期望 %v", report.Extra, tt.wantExtra)
+				}
+				if !equalPaths(report.Corrupted, tt.wantCorrupted) {
+					t.Errorf("损坏的文件为 %v，期望 %v", report.Corrupted, tt.wantCorrupted)
+				}
+
+				// 有问题的备份让 verify 以非零状态码退出
+				wantOK := tt.wantMissing == nil && tt.wantExtra == nil && tt.wantCorrupted == nil
+				if err := VerifyBackups(backups, true); (err == nil) != wantOK {
+					t.Errorf("VerifyBackups 返回 %v，期望校验通过: %v", err, wantOK)
+				}
+			})
+		}
+	}
+}
+
+func TestVerifyBackupsWithoutManifest(t *testing.T) {
+	backupDir := t.TempDir()
+	now := time.Now()
+	b := snapshot.Backup{Name: snapshot.NewName(now), Format: snapshot.FormatDir, Time: now}
+	b.Path = filepath.Join(backupDir, b.Name)
+	if err := os.MkdirAll(b.Path, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := VerifyBackups([]snapshot.Backup{b}, true); err != nil {
+		t.Errorf("校验所有备份时应跳过没有清单的备份: %v", err)
+	}
+	if err := VerifyBackups([]snapshot.Backup{b}, false); err == nil {
+		t.Errorf("指定的备份没有清单时应返回错误")
+	}
+}
+
+// equalPaths 比较两个路径列表，nil 和空列表视为相同
+func equalPaths(got, want []string) bool {
+	if len(got) == 0 && len(want) == 0 {
+		return true
+	}
+	return reflect.DeepEqual(got, want)
+}
@@ -0,0 +1,195 @@
+package clone
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/lizhening/WtfBackup/config"
+	"github.com/lizhening/WtfBackup/pkg/fileutil"
+	"github.com/lizhening/WtfBackup/pkg/logger"
+	"github.com/lizhening/WtfBackup/pkg/process"
+	"github.com/lizhening/WtfBackup/pkg/snapshot"
+	"github.com/lizhening/WtfBackup/pkg/wtf"
+	"github.com/lizhening/WtfBackup/undo"
+)
+
+// CharacterFiles 除 SavedVariables 以外默认会被克隆的角色设置文件
+var CharacterFiles = []string{"layout-local.txt", "bindings-cache.wtf", "macros-cache.txt"}
+
+// Options 克隆选项
+type Options struct {
+	// 读取设置的来源: live 表示当前的WTF文件夹，其他值按 snapshot.Select 选择备份
+	Source string
+	// 只克隆这些插件的角色设置，为空时克隆所有插件的设置以及 CharacterFiles
+	Addons []string
+	// 克隆角色文件夹中的所有文件（包括 config-cache.wtf、AddOns.txt 等）
+	Full bool
+	// 即使来源中的 SavedVariables 文件可能已损坏也继续克隆
+	Force bool
+	// 显示进度条
+	ShowProgress bool
+	// 游戏正在运行时仍然克隆
+	AllowRunning bool
+	// 检查游戏是否正在运行时使用的进程列表，为 nil 时使用 process.Default
+	Processes process.Lister
+}
+
+// CloneCharacter 将角色 from 的设置复制到当前WTF文件夹中的角色 to
+// 写入之前会为即将覆盖或创建的文件保存快照，可以使用 undo 命令撤销
+func CloneCharacter(ctx context.Context, cfg config.Config, from, to wtf.Location, fileOp fileutil.FileOperator, opts Options) error {
+	if from.AccountWide() || to.AccountWide() {
+		return fmt.Errorf("克隆需要指定 <账号>/<服务器>/<角色>")
+	}
+	if opts.Full && len(opts.Addons) > 0 {
+		return fmt.Errorf("完整克隆时不能指定插件")
+	}
+	if opts.Source == "" {
+		opts.Source = snapshot.Live
+	}
+	if opts.Source == snapshot.Live && strings.EqualFold(from.Dir(), to.Dir()) {
+		return fmt.Errorf("来源和目标是同一个角色")
+	}
+	// 试运行不写入文件，只给出警告
+	if err := process.CheckBeforeWrite(opts.Processes, opts.AllowRunning || fileutil.IsDryRun(fileOp)); err != nil {
+		return err
+	}
+
+	src, err := snapshot.OpenSource(cfg.WtfPath, cfg.BackupDir, opts.Source)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	srcDir := from.Dir()
+	if info, err := fs.Stat(src, srcDir); err != nil || !info.IsDir() {
+		return fmt.Errorf("%s 中没有角色 %s", src.Name, from)
+	}
+	logger.Info("将从 %s 中复制角色 %s 的设置到 %s", src.Name, from, to)
+
+	files, err := characterFiles(src, srcDir, opts)
+	if err != nil {
+		return fmt.Errorf("读取角色 %s 的设置失败: %w", from, err)
+	}
+	if len(files) == 0 {
+		logger.Warn("角色 %s 没有需要复制的设置", from)
+		return nil
+	}
+
+	// 复制前检查来源中的 SavedVariables，避免把损坏的文件复制给其他角色
+	if err := checkFiles(src, srcDir, files, opts.Force); err != nil {
+		return err
+	}
+
+	// 目标文件相对于WTF文件夹的路径
+	dstDir := to.Dir()
+	targets := make([]string, len(files))
+	for i, relPath := range files {
+		targets[i] = path.Join(dstDir, relPath)
+	}
+
+	// 保存即将被覆盖或创建的文件，以便撤销这次克隆（试运行时不需要）
+	if !fileutil.IsDryRun(fileOp) {
+		action := fmt.Sprintf("克隆角色 %s 的设置到 %s (来自 %s)", from, to, src.Name)
+		s, err := undo.Begin(ctx, cfg.BackupDir, cfg.WtfPath, action, targets, fileOp)
+		if err != nil {
+			return err
+		}
+		logger.Info("已保存克隆前的文件，可以使用 undo 命令撤销这次克隆 (快照 %s)", s.ID)
+	}
+
+	for i, relPath := range files {
+		destPath := filepath.Join(cfg.WtfPath, filepath.FromSlash(targets[i]))
+		if err := fileOp.EnsureDir(filepath.Dir(destPath)); err != nil {
+			return err
+		}
+		if err := fileOp.CopyFromFS(ctx, src, path.Join(srcDir, relPath), destPath, opts.ShowProgress); err != nil {
+			if ctx.Err() != nil && !fileutil.IsDryRun(fileOp) {
+				return fmt.Errorf("克隆已中断，已复制的文件可以使用 undo 命令撤销: %w", err)
+			}
+			return fmt.Errorf("复制文件 %s 失败: %w", relPath, err)
+		}
+		if !fileutil.IsDryRun(fileOp) {
+			logger.Info("已复制: %s", targets[i])
+		}
+	}
+	return nil
+}
+
+// characterFiles 返回角色文件夹中需要克隆的文件，路径相对于角色文件夹
+func characterFiles(src fs.FS, srcDir string, opts Options) ([]string, error) {
+	var files []string
+	err := fs.WalkDir(src, srcDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		relPath := strings.TrimPrefix(p, srcDir+"/")
+		if selected(p, relPath, opts) {
+			files = append(files, relPath)
+		}
+		return nil
+	})
+	return files, err
+}
+
+// selected 判断角色文件夹中的文件是否需要克隆
+// fullPath 为相对于WTF文件夹的路径，relPath 为相对于角色文件夹的路径
+func selected(fullPath, relPath string, opts Options) bool {
+	if opts.Full {
+		return true
+	}
+
+	if len(opts.Addons) > 0 {
+		for _, addon := range opts.Addons {
+			if wtf.IsAddonFile(fullPath, addon) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if wtf.IsSavedVariablesFile(fullPath) {
+		return true
+	}
+	for _, name := range CharacterFiles {
+		if relPath == name {
+			return true
+		}
+	}
+	return false
+}
+
+// checkFiles 检查要复制的 SavedVariables 文件是否为空或无法解析，
+// 发现损坏的文件时拒绝克隆，force 为 true 时只给出警告
+func checkFiles(src fs.FS, srcDir string, files []string, force bool) error {
+	var corrupt int
+	for _, relPath := range files {
+		p := path.Join(srcDir, relPath)
+		if !wtf.IsSavedVariablesFile(p) {
+			continue
+		}
+		data, err := fs.ReadFile(src, p)
+		if err != nil {
+			return fmt.Errorf("读取文件 %s 失败: %w", p, err)
+		}
+		if err := wtf.CheckSavedVariables(data); err != nil {
+			logger.Warn("来源中的文件可能已损坏: %s: %v", p, err)
+			corrupt++
+		}
+	}
+	if corrupt == 0 {
+		return nil
+	}
+	if !force {
+		return fmt.Errorf("来源中有 %d 个文件可能已损坏，已取消克隆 (使用 -force 强制克隆)", corrupt)
+	}
+	logger.Warn("已指定 -force，仍将复制这些文件")
+	return nil
+}
@@ -0,0 +1,220 @@
+package clone
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/lizhening/WtfBackup/config"
+	"github.com/lizhening/WtfBackup/pkg/fileutil"
+	"github.com/lizhening/WtfBackup/pkg/wtf"
+	"github.com/lizhening/WtfBackup/undo"
+)
+
+// fakeLister 返回固定的进程列表
+type fakeLister []string
+
+func (l fakeLister) List() ([]string, error) {
+	return l, nil
+}
+
+const (
+	fromDir = "Account/ACC/Realm/From"
+	toDir   = "Account/ACC/Realm/To"
+)
+
+// writeFile 写入 WTF 文件夹中的文件
+func writeFile(t *testing.T, wtfPath, relPath, content string) {
+	t.Helper()
+	path := filepath.Join(wtfPath, filepath.FromSlash(relPath))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// readFile 读取 WTF 文件夹中的文件，文件不存在时返回 false
+func readFile(t *testing.T, wtfPath, relPath string) (string, bool) {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(wtfPath, filepath.FromSlash(relPath)))
+	if os.IsNotExist(err) {
+		return "", false
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data), true
+}
+
+// newTestWtf 创建有两个角色的WTF文件夹
+func newTestWtf(t *testing.T) string {
+	t.Helper()
+	wtfPath := t.TempDir()
+	for relPath, content := range map[string]string{
+		fromDir + "/SavedVariables/Addon.lua":             "AddonDB = { x = 1 }",
+		fromDir + "/SavedVariables/Other.lua":             "OtherDB = {}",
+		fromDir + "/SavedVariablesPerCharacter/Addon.lua": "AddonCharDB = { y = 2 }",
+		fromDir + "/layout-local.txt":                     "from layout",
+		fromDir + "/bindings-cache.wtf":                   "from bindings",
+		fromDir + "/macros-cache.txt":                     "from macros",
+		fromDir + "/config-cache.wtf":                     "from config",
+		fromDir + "/AddOns.txt":                           "Addon: enabled",
+		toDir + "/layout-local.txt":                       "to layout",
+		toDir + "/config-cache.wtf":                       "to config",
+	} {
+		writeFile(t, wtfPath, relPath, content)
+	}
+	return wtfPath
+}
+
+func TestCharacterFiles(t *testing.T) {
+	wtfPath := newTestWtf(t)
+	tests := []struct {
+		name string
+		opts Options
+		want []string
+	}{
+		{
+			"默认",
+			Options{},
+			[]string{
+				"SavedVariables/Addon.lua", "SavedVariables/Other.lua", "SavedVariablesPerCharacter/Addon.lua",
+				"bindings-cache.wtf", "layout-local.txt", "macros-cache.txt",
+			},
+		},
+		{
+			"指定插件",
+			Options{Addons: []string{"Addon"}},
+			[]string{"SavedVariables/Addon.lua", "SavedVariablesPerCharacter/Addon.lua"},
+		},
+		{
+			"完整克隆",
+			Options{Full: true},
+			[]string{
+				"AddOns.txt", "SavedVariables/Addon.lua", "SavedVariables/Other.lua",
+				"SavedVariablesPerCharacter/Addon.lua", "bindings-cache.wtf", "config-cache.wtf",
+				"layout-local.txt", "macros-cache.txt",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := characterFiles(os.DirFS(wtfPath), fromDir, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("需要克隆的文件为 %v，期望 %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCloneCharacter(t *testing.T) {
+	ctx := context.Background()
+	wtfPath := newTestWtf(t)
+	cfg := config.Config{WtfPath: wtfPath, BackupDir: t.TempDir()}
+	from, _ := wtf.ParseCharacter(fromDir)
+	to, _ := wtf.ParseCharacter(toDir)
+	fileOp := fileutil.NewDefaultFileOperator(32*1024, 1)
+
+	if err := CloneCharacter(ctx, cfg, from, to, fileOp, Options{Processes: fakeLister{}}); err != nil {
+		t.Fatalf("克隆失败: %v", err)
+	}
+	for relPath, want := range map[string]string{
+		toDir + "/SavedVariables/Addon.lua": "AddonDB = { x = 1 }",
+		toDir + "/layout-local.txt":         "from layout",
+		toDir + "/bindings-cache.wtf":       "from bindings",
+		toDir + "/macros-cache.txt":         "from macros",
+		toDir + "/config-cache.wtf":         "to config",
+	} {
+		if got, _ := readFile(t, wtfPath, relPath); got != want {
+			t.Errorf("%s 的内容为 %q，期望 %q", relPath, got, want)
+		}
+	}
+	if _, ok := readFile(t, wtfPath, toDir+"/AddOns.txt"); ok {
+		t.Errorf("默认不应克隆 AddOns.txt")
+	}
+
+	// 克隆可以撤销：覆盖的文件还原，新建的文件删除
+	snapshots, err := undo.List(cfg.BackupDir)
+	if err != nil || len(snapshots) != 1 {
+		t.Fatalf("找到 %d 个克隆前快照: %v", len(snapshots), err)
+	}
+	if err := undo.Undo(ctx, snapshots[0], fileOp); err != nil {
+		t.Fatalf("撤销克隆失败: %v", err)
+	}
+	if got, _ := readFile(t, wtfPath, toDir+"/layout-local.txt"); got != "to layout" {
+		t.Errorf("撤销后 layout-local.txt 的内容为 %q", got)
+	}
+	if _, ok := readFile(t, wtfPath, toDir+"/SavedVariables/Addon.lua"); ok {
+		t.Errorf("撤销后克隆时新建的文件应被删除")
+	}
+}
+
+func TestCloneCharacterErrors(t *testing.T) {
+	wtfPath := newTestWtf(t)
+	cfg := config.Config{WtfPath: wtfPath, BackupDir: t.TempDir()}
+	from, _ := wtf.ParseCharacter(fromDir)
+	to, _ := wtf.ParseCharacter(toDir)
+	missing, _ := wtf.ParseCharacter("ACC/Realm/Missing")
+	fileOp := fileutil.NewDefaultFileOperator(32*1024, 1)
+
+	tests := []struct {
+		name     string
+		from, to wtf.Location
+		opts     Options
+	}{
+		{"完整克隆时指定插件", from, to, Options{Full: true, Addons: []string{"Addon"}}},
+		{"同一个角色", from, from, Options{}},
+		{"来源角色不存在", missing, to, Options{}},
+		{"游戏正在运行", from, to, Options{Processes: fakeLister{"Wow.exe"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.opts.Processes == nil {
+				tt.opts.Processes = fakeLister{}
+			}
+			if err := CloneCharacter(context.Background(), cfg, tt.from, tt.to, fileOp, tt.opts); err == nil {
+				t.Errorf("应返回错误")
+			}
+		})
+	}
+	if got, _ := readFile(t, wtfPath, toDir+"/layout-local.txt"); got != "to layout" {
+		t.Errorf("克隆失败时不应修改目标角色")
+	}
+}
+
+func TestCheckFiles(t *testing.T) {
+	wtfPath := newTestWtf(t)
+	writeFile(t, wtfPath, fromDir+"/SavedVariables/Broken.lua", "BrokenDB = {")
+	writeFile(t, wtfPath, fromDir+"/SavedVariables/Empty.lua", "")
+	src := os.DirFS(wtfPath)
+
+	good := []string{"SavedVariables/Addon.lua", "layout-local.txt"}
+	if err := checkFiles(src, fromDir, good, false); err != nil {
+		t.Errorf("正常的文件检查失败: %v", err)
+	}
+
+	// layout-local.txt 等不是 SavedVariables 的文件不做检查
+	writeFile(t, wtfPath, fromDir+"/layout-local.txt", "")
+	if err := checkFiles(src, fromDir, good, false); err != nil {
+		t.Errorf("不应检查 SavedVariables 以外的文件: %v", err)
+	}
+
+	for _, relPath := range []string{"SavedVariables/Broken.lua", "SavedVariables/Empty.lua"} {
+		files := append([]string{"SavedVariables/Addon.lua"}, relPath)
+		if err := checkFiles(src, fromDir, files, false); err == nil {
+			t.Errorf("%s 损坏时应拒绝克隆", relPath)
+		}
+		if err := checkFiles(src, fromDir, files, true); err != nil {
+			t.Errorf("指定 -force 时 %s 损坏不应返回错误: %v", relPath, err)
+		}
+	}
+}
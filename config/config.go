@@ -7,6 +7,10 @@ import (
 	"runtime"
 	"strings"
 
+	"github.com/lizhening/WtfBackup/pkg/detect"
+	"github.com/lizhening/WtfBackup/pkg/logger"
+	"github.com/lizhening/WtfBackup/pkg/process"
+	"github.com/lizhening/WtfBackup/pkg/retention"
 	"gopkg.in/yaml.v3"
 )
 
@@ -18,6 +22,214 @@ type Config struct {
 	BackupDir string `yaml:"backup_dir"`
 	// 需要恢复的插件列表
 	Addons []string `yaml:"addons"`
+	// 备份格式: dir, zip, tar.gz, tar.zst, repo (默认 dir)
+	Format string `yaml:"format,omitempty"`
+	// 增量备份：未变化的文件硬链接到上一个文件夹备份 (仅文件夹格式)
+	Incremental bool `yaml:"incremental,omitempty"`
+	// 保留的备份数量，为 0 时使用 backup -keep 的默认值
+	Keep int `yaml:"keep,omitempty"`
+	// 保留策略：最近 N 小时内的全部备份，以及每天、每周、每月各一个备份
+	Retention retention.Policy `yaml:"retention,omitempty"`
+	// 复制文件夹时同时复制的文件数量，为 0 时使用默认值
+	Concurrency int `yaml:"concurrency,omitempty"`
+	// 备份时游戏正在运行的处理方式: warn (警告后继续), wait (等待游戏退出), abort (取消备份)，默认 warn
+	OnGameRunning string `yaml:"on_game_running,omitempty"`
+	// 日志文件设置
+	Log LogConfig `yaml:"log,omitempty"`
+	// 多个WTF文件夹（例如正式服和怀旧服），每个来源的备份保存在备份文件夹下以来源名称命名的子文件夹中
+	Sources []Source `yaml:"sources,omitempty"`
+
+	// WtfPath 是自动检测到的而不是配置文件中的设置（不保存）
+	WtfPathDetected bool `yaml:"-"`
+	// 由 ForSource 生成的配置对应的来源名称（不保存）
+	SourceName string `yaml:"-"`
+}
+
+// LogConfig 日志文件设置
+type LogConfig struct {
+	// 日志文件路径，为空时不写入日志文件
+	File string `yaml:"file,omitempty"`
+	// 写入日志文件的级别: debug, info, warn, error，默认 info
+	Level string `yaml:"level,omitempty"`
+	// 日志文件的格式: text, json，默认 text
+	Format string `yaml:"format,omitempty"`
+	// 日志文件超过多少 MB 时轮换，默认 10
+	MaxSizeMB int `yaml:"max_size_mb,omitempty"`
+	// 轮换后的日志文件保留多少天，默认 30；日志文件超过这个天数没有写入时也会轮换
+	MaxAgeDays int `yaml:"max_age_days,omitempty"`
+	// 最多保留多少个轮换后的日志文件，默认 5
+	MaxBackups int `yaml:"max_backups,omitempty"`
+}
+
+// 日志文件轮换的默认设置
+const (
+	DefaultLogMaxSizeMB  = 10
+	DefaultLogMaxAgeDays = 30
+	DefaultLogMaxBackups = 5
+)
+
+// Validate 检查日志设置是否有效
+func (l LogConfig) Validate() error {
+	if _, err := logger.ParseLevel(l.Level); err != nil {
+		return err
+	}
+	if _, err := logger.ParseFormat(l.Format); err != nil {
+		return err
+	}
+	if l.MaxSizeMB < 0 || l.MaxAgeDays < 0 || l.MaxBackups < 0 {
+		return fmt.Errorf("日志轮换设置不能为负数")
+	}
+	return nil
+}
+
+// AllSources 选择所有来源时使用的名称
+const AllSources = "all"
+
+// Source 一个命名的WTF文件夹，例如 retail、classic
+type Source struct {
+	// 来源名称，同时也是备份文件夹下的子文件夹名称
+	Name string `yaml:"name"`
+	// WTF文件夹路径
+	WtfPath string `yaml:"wtf_path"`
+	// 需要恢复的插件列表，为空时使用全局的插件列表
+	Addons []string `yaml:"addons,omitempty"`
+	// 保留的备份数量，为 0 时使用全局设置
+	Keep int `yaml:"keep,omitempty"`
+	// 保留策略，没有设置时使用全局设置
+	Retention retention.Policy `yaml:"retention,omitempty"`
+}
+
+// FindSource 按名称查找来源
+func (c *Config) FindSource(name string) (*Source, bool) {
+	for i := range c.Sources {
+		if c.Sources[i].Name == name {
+			return &c.Sources[i], true
+		}
+	}
+	return nil, false
+}
+
+// SourceNames 返回所有来源的名称
+func (c *Config) SourceNames() []string {
+	names := make([]string, len(c.Sources))
+	for i, source := range c.Sources {
+		names[i] = source.Name
+	}
+	return names
+}
+
+// ForSource 返回某个来源对应的配置：使用来源的WTF文件夹、插件列表和保留数量，
+// 备份文件夹为全局备份文件夹下以来源名称命名的子文件夹
+func (c Config) ForSource(name string) (Config, error) {
+	source, ok := c.FindSource(name)
+	if !ok {
+		return Config{}, fmt.Errorf("没有名为 %s 的来源 (可选: %s)", name, strings.Join(c.SourceNames(), ", "))
+	}
+
+	sourceCfg := c
+	sourceCfg.Sources = nil
+	sourceCfg.WtfPathDetected = false
+	sourceCfg.SourceName = source.Name
+	sourceCfg.WtfPath = NormalizePath(source.WtfPath)
+	if c.BackupDir != "" {
+		sourceCfg.BackupDir = filepath.Join(c.BackupDir, source.Name)
+	}
+	if len(source.Addons) > 0 {
+		sourceCfg.Addons = source.Addons
+	}
+	if source.Keep > 0 {
+		sourceCfg.Keep = source.Keep
+	}
+	if !source.Retention.IsZero() {
+		sourceCfg.Retention = source.Retention
+	}
+	return sourceCfg, nil
+}
+
+// DefaultKeep 没有设置保留数量和保留策略时保留的备份数量
+const DefaultKeep = 5
+
+// RetentionPolicy 返回清理旧备份时使用的保留策略
+// keep 大于 0 时（例如命令行中指定了 -keep）覆盖配置文件中的保留数量；
+// 既没有设置保留数量也没有设置保留策略时保留最新的 DefaultKeep 个备份
+func (c Config) RetentionPolicy(keep int) retention.Policy {
+	policy := c.Retention
+	if c.Keep > 0 {
+		policy.Last = c.Keep
+	}
+	if keep > 0 {
+		policy.Last = keep
+	}
+	if policy.IsZero() {
+		policy.Last = DefaultKeep
+	}
+	return policy
+}
+
+// Select 按名称选择要操作的配置
+// 名称为空时使用全局的WTF文件夹；没有设置全局WTF文件夹但配置了来源时等同于 all。
+// 名称为 all 时返回所有来源，其他名称返回对应的来源
+func (c Config) Select(name string) ([]Config, error) {
+	if name == "" {
+		if c.WtfPath != "" || len(c.Sources) == 0 {
+			return []Config{c}, nil
+		}
+		name = AllSources
+	}
+
+	if name == AllSources {
+		if len(c.Sources) == 0 {
+			return nil, fmt.Errorf("配置文件中没有设置来源")
+		}
+		configs := make([]Config, 0, len(c.Sources))
+		for _, source := range c.Sources {
+			sourceCfg, err := c.ForSource(source.Name)
+			if err != nil {
+				return nil, err
+			}
+			configs = append(configs, sourceCfg)
+		}
+		return configs, nil
+	}
+
+	sourceCfg, err := c.ForSource(name)
+	if err != nil {
+		return nil, err
+	}
+	return []Config{sourceCfg}, nil
+}
+
+// ValidateSourceName 检查来源名称能否作为备份文件夹下的子文件夹名称
+func ValidateSourceName(name string) error {
+	switch {
+	case name == "":
+		return fmt.Errorf("来源名称不能为空")
+	case name == AllSources:
+		return fmt.Errorf("来源名称不能为 %s", AllSources)
+	case name == "." || name == ".." || strings.ContainsAny(name, `/\:*?"<>|`):
+		return fmt.Errorf("来源名称 %q 包含无效的字符", name)
+	case strings.HasPrefix(name, "WTF_") || name == "objects":
+		return fmt.Errorf("来源名称 %q 与备份文件夹中的保留名称冲突", name)
+	}
+	return nil
+}
+
+// validateSources 检查来源名称有效且不重复
+func validateSources(sources []Source) error {
+	seen := make(map[string]bool)
+	for _, source := range sources {
+		if err := ValidateSourceName(source.Name); err != nil {
+			return err
+		}
+		if err := source.Retention.Validate(); err != nil {
+			return fmt.Errorf("来源 %s 的保留策略无效: %w", source.Name, err)
+		}
+		if seen[source.Name] {
+			return fmt.Errorf("来源名称 %s 重复", source.Name)
+		}
+		seen[source.Name] = true
+	}
+	return nil
 }
 
 // DefaultConfigPath 返回默认配置文件路径
@@ -34,11 +246,12 @@ func DefaultConfigPath() string {
 func LoadConfig(configPath string) (*Config, error) {
 	// 如果配置文件不存在，返回默认配置
 	if _, err := os.Stat(configPath); os.IsNotExist(err) {
-		return &Config{
+		config := &Config{
 			WtfPath:   "",
 			BackupDir: "",
 			Addons:    []string{},
-		}, nil
+		}
+		return config, nil
 	}
 
 	// 读取配置文件
@@ -56,21 +269,54 @@ func LoadConfig(configPath string) (*Config, error) {
 	// 规范化路径
 	config.WtfPath = NormalizePath(config.WtfPath)
 	config.BackupDir = NormalizePath(config.BackupDir)
+	if err := validateSources(config.Sources); err != nil {
+		return nil, fmt.Errorf("配置文件中的来源无效: %w", err)
+	}
+	if err := config.Retention.Validate(); err != nil {
+		return nil, fmt.Errorf("配置文件中的保留策略无效: %w", err)
+	}
+	if _, err := process.ParsePolicy(config.OnGameRunning); err != nil {
+		return nil, fmt.Errorf("配置文件中的 on_game_running 无效: %w", err)
+	}
+	if err := config.Log.Validate(); err != nil {
+		return nil, fmt.Errorf("配置文件中的日志设置无效: %w", err)
+	}
+	config.Log.File = NormalizePath(config.Log.File)
+	for i := range config.Sources {
+		config.Sources[i].WtfPath = NormalizePath(config.Sources[i].WtfPath)
+	}
 
 	return &config, nil
 }
 
+// DetectWtfPath 没有配置WTF文件夹（也没有配置来源）时，使用自动检测到的游戏安装中的WTF文件夹，
+// 检测到时返回 true。检测需要扫描磁盘，只应在用到WTF文件夹的命令中调用
+func (c *Config) DetectWtfPath() bool {
+	if c.WtfPath != "" || len(c.Sources) > 0 {
+		return false
+	}
+	install, ok := detect.Preferred(detect.Detect())
+	if !ok {
+		return false
+	}
+	c.WtfPath = install.WtfPath
+	c.WtfPathDetected = true
+	return true
+}
+
 // SaveConfig 保存配置到文件
 func SaveConfig(config *Config, configPath string) error {
 	// 将路径规范化后再保存
-	configToSave := &Config{
-		WtfPath:   NormalizePath(config.WtfPath),
-		BackupDir: NormalizePath(config.BackupDir),
-		Addons:    config.Addons,
+	configToSave := *config
+	configToSave.WtfPath = NormalizePath(config.WtfPath)
+	configToSave.BackupDir = NormalizePath(config.BackupDir)
+	// 自动检测到的WTF文件夹不保存，游戏安装位置变化后仍会重新检测
+	if config.WtfPathDetected {
+		configToSave.WtfPath = ""
 	}
 
 	// 将配置序列化为YAML
-	data, err := yaml.Marshal(configToSave)
+	data, err := yaml.Marshal(&configToSave)
 	if err != nil {
 		return fmt.Errorf("序列化配置失败: %w", err)
 	}
@@ -0,0 +1,54 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSaveConfigSkipsDetectedWtfPath(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	cfg := &Config{WtfPath: "/games/World of Warcraft/_retail_/WTF", BackupDir: "/backups", WtfPathDetected: true}
+	if err := SaveConfig(cfg, path); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "_retail_") {
+		t.Errorf("保存了自动检测到的WTF文件夹:\n%s", data)
+	}
+
+	loaded, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.WtfPath != "" || loaded.WtfPathDetected {
+		t.Errorf("LoadConfig 不应自动检测WTF文件夹，得到 %q", loaded.WtfPath)
+	}
+	if loaded.BackupDir != NormalizePath("/backups") {
+		t.Errorf("备份文件夹为 %q", loaded.BackupDir)
+	}
+
+	// 用户设置的WTF文件夹照常保存
+	cfg.WtfPathDetected = false
+	if err := SaveConfig(cfg, path); err != nil {
+		t.Fatal(err)
+	}
+	if loaded, err := LoadConfig(path); err != nil || loaded.WtfPath != NormalizePath(cfg.WtfPath) {
+		t.Errorf("WTF文件夹为 %q, %v，期望 %q", loaded.WtfPath, err, cfg.WtfPath)
+	}
+}
+
+func TestDetectWtfPathKeepsConfiguredPath(t *testing.T) {
+	cfg := &Config{WtfPath: "/wtf"}
+	if cfg.DetectWtfPath() || cfg.WtfPath != "/wtf" || cfg.WtfPathDetected {
+		t.Errorf("已配置WTF文件夹时不应自动检测")
+	}
+	cfg = &Config{Sources: []Source{{Name: "retail", WtfPath: "/wtf"}}}
+	if cfg.DetectWtfPath() || cfg.WtfPath != "" {
+		t.Errorf("配置了来源时不应自动检测")
+	}
+}
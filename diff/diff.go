@@ -0,0 +1,116 @@
+package diff
+
+import (
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+
+	"github.com/lizhening/WtfBackup/pkg/luasv"
+	"github.com/lizhening/WtfBackup/pkg/snapshot"
+	"github.com/lizhening/WtfBackup/pkg/wtf"
+)
+
+// FileStatus 文件级别的变化
+type FileStatus int
+
+const (
+	// FileChanged 两边都存在的文件
+	FileChanged FileStatus = iota
+	// FileAdded 只在新版本中存在的文件
+	FileAdded
+	// FileRemoved 只在旧版本中存在的文件
+	FileRemoved
+)
+
+// FileDiff 一个 SavedVariables 文件的比较结果
+type FileDiff struct {
+	// 相对于WTF文件夹的路径
+	Path   string
+	Status FileStatus
+	// 两边都存在时的逐键变化
+	Changes []luasv.Change
+	// 解析失败时的错误
+	Err error
+}
+
+// DiffAddon 比较插件在两个版本中的所有 SavedVariables 文件（账号级和角色级）
+func DiffAddon(from, to *snapshot.Source, addonName string) ([]FileDiff, error) {
+	fromFiles, err := addonFiles(from, addonName)
+	if err != nil {
+		return nil, fmt.Errorf("读取 %s 失败: %w", from.Name, err)
+	}
+	toFiles, err := addonFiles(to, addonName)
+	if err != nil {
+		return nil, fmt.Errorf("读取 %s 失败: %w", to.Name, err)
+	}
+
+	paths := make(map[string]bool)
+	for path := range fromFiles {
+		paths[path] = true
+	}
+	for path := range toFiles {
+		paths[path] = true
+	}
+	sorted := make([]string, 0, len(paths))
+	for path := range paths {
+		sorted = append(sorted, path)
+	}
+	sort.Strings(sorted)
+
+	var diffs []FileDiff
+	for _, path := range sorted {
+		switch {
+		case !toFiles[path]:
+			diffs = append(diffs, FileDiff{Path: path, Status: FileRemoved})
+		case !fromFiles[path]:
+			diffs = append(diffs, FileDiff{Path: path, Status: FileAdded})
+		default:
+			d := FileDiff{Path: path, Status: FileChanged}
+			d.Changes, d.Err = diffFile(from, to, path)
+			diffs = append(diffs, d)
+		}
+	}
+	return diffs, nil
+}
+
+// addonFiles 返回插件的所有 SavedVariables 文件
+func addonFiles(fsys fs.FS, addonName string) (map[string]bool, error) {
+	files := make(map[string]bool)
+	err := fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && strings.HasSuffix(path, ".lua") && wtf.IsAddonFile(path, addonName) {
+			files[path] = true
+		}
+		return nil
+	})
+	return files, err
+}
+
+// diffFile 解析并比较两边的同一个文件
+func diffFile(from, to *snapshot.Source, path string) ([]luasv.Change, error) {
+	a, err := parseFile(from, path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", from.Name, err)
+	}
+	b, err := parseFile(to, path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", to.Name, err)
+	}
+	return luasv.Diff(a, b), nil
+}
+
+// parseFile 读取并解析 SavedVariables 文件
+func parseFile(fsys fs.FS, path string) (*luasv.File, error) {
+	data, err := fs.ReadFile(fsys, path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := luasv.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("解析失败: %w", err)
+	}
+	return f, nil
+}
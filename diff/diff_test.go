@@ -0,0 +1,103 @@
+package diff
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lizhening/WtfBackup/pkg/luasv"
+	"github.com/lizhening/WtfBackup/pkg/snapshot"
+)
+
+// newSource 用给定的文件创建一个比较的一方
+func newSource(t *testing.T, name string, files map[string]string) *snapshot.Source {
+	t.Helper()
+	dir := t.TempDir()
+	for relPath, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(relPath))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return &snapshot.Source{Name: name, FS: os.DirFS(dir)}
+}
+
+func TestDiffAddon(t *testing.T) {
+	from := newSource(t, "from", map[string]string{
+		"Account/ACC/SavedVariables/Addon.lua":                        "AddonDB = { scale = 1, old = true }",
+		"Account/ACC/SavedVariables/Other.lua":                        "OtherDB = { a = 1 }",
+		"Account/ACC/SavedVariables/Addon.lua.bak":                    "AddonDB = {}",
+		"Account/ACC/Realm/Char/SavedVariables/Addon.lua":             "AddonCharDB = { a = 1 }",
+		"Account/ACC/Realm/Char/SavedVariablesPerCharacter/Addon.lua": "AddonPerChar = { b = 1 }",
+		"Account/ACC/Realm/Gone/SavedVariables/Addon.lua":             "AddonCharDB = {}",
+		"Account/ACC/Realm/Broken/SavedVariables/Addon.lua":           "AddonCharDB = {}",
+	})
+	to := newSource(t, "to", map[string]string{
+		"Account/ACC/SavedVariables/Addon.lua":                        "AddonDB = { scale = 2, new = \"x\" }",
+		"Account/ACC/SavedVariables/Other.lua":                        "OtherDB = { a = 2 }",
+		"Account/ACC/Realm/Char/SavedVariables/Addon.lua":             "AddonCharDB = { a = 1 }",
+		"Account/ACC/Realm/Char/SavedVariablesPerCharacter/Addon.lua": "AddonPerChar = { b = 2 }",
+		"Account/ACC/Realm/New/SavedVariables/Addon.lua":              "AddonCharDB = {}",
+		"Account/ACC/Realm/Broken/SavedVariables/Addon.lua":           "AddonCharDB = {",
+	})
+
+	diffs, err := DiffAddon(from, to, "Addon")
+	if err != nil {
+		t.Fatalf("比较失败: %v", err)
+	}
+
+	type change struct {
+		kind luasv.ChangeKind
+		path string
+	}
+	want := []struct {
+		path    string
+		status  FileStatus
+		changes []change
+		err     bool
+	}{
+		{"Account/ACC/Realm/Broken/SavedVariables/Addon.lua", FileChanged, nil, true},
+		{"Account/ACC/Realm/Char/SavedVariables/Addon.lua", FileChanged, nil, false},
+		{"Account/ACC/Realm/Char/SavedVariablesPerCharacter/Addon.lua", FileChanged, []change{{luasv.Changed, "AddonPerChar.b"}}, false},
+		{"Account/ACC/Realm/Gone/SavedVariables/Addon.lua", FileRemoved, nil, false},
+		{"Account/ACC/Realm/New/SavedVariables/Addon.lua", FileAdded, nil, false},
+		{"Account/ACC/SavedVariables/Addon.lua", FileChanged, []change{
+			{luasv.Changed, "AddonDB.scale"},
+			{luasv.Removed, "AddonDB.old"},
+			{luasv.Added, "AddonDB.new"},
+		}, false},
+	}
+	if len(diffs) != len(want) {
+		for _, d := range diffs {
+			t.Logf("%s %v", d.Path, d.Status)
+		}
+		t.Fatalf("比较了 %d 个文件，期望 %d 个", len(diffs), len(want))
+	}
+	for i, w := range want {
+		d := diffs[i]
+		if d.Path != w.path || d.Status != w.status {
+			t.Errorf("第 %d 个文件为 %s (%v)，期望 %s (%v)", i+1, d.Path, d.Status, w.path, w.status)
+			continue
+		}
+		if (d.Err != nil) != w.err {
+			t.Errorf("%s 的错误为 %v", d.Path, d.Err)
+		}
+		var got []change
+		for _, c := range d.Changes {
+			got = append(got, change{c.Kind, c.Path})
+		}
+		if len(got) != len(w.changes) {
+			t.Errorf("%s 的变化为 %v，期望 %v", d.Path, got, w.changes)
+			continue
+		}
+		for j := range got {
+			if got[j] != w.changes[j] {
+				t.Errorf("%s 的变化为 %v，期望 %v", d.Path, got, w.changes)
+				break
+			}
+		}
+	}
+}
@@ -2,4 +2,7 @@ module github.com/lizhening/WtfBackup
 
 go 1.21
 
-require gopkg.in/yaml.v3 v3.0.1 // indirect
+require (
+	github.com/klauspost/compress v1.17.11
+	gopkg.in/yaml.v3 v3.0.1
+)
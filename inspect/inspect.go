@@ -0,0 +1,211 @@
+package inspect
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/lizhening/WtfBackup/pkg/progress"
+)
+
+// SavedVariables 一个插件的 SavedVariables 文件
+type SavedVariables struct {
+	// 插件名称（文件名去掉 .lua）
+	Addon string `json:"addon"`
+	// 相对于WTF文件夹的路径
+	Path string `json:"path"`
+	// 是否位于 SavedVariablesPerCharacter 文件夹中
+	PerCharacter bool      `json:"per_character"`
+	Size         int64     `json:"size"`
+	ModTime      time.Time `json:"mtime"`
+}
+
+// Character 一个角色及其插件设置
+type Character struct {
+	Name           string           `json:"name"`
+	SavedVariables []SavedVariables `json:"saved_variables"`
+}
+
+// Realm 一个服务器及其中的角色
+type Realm struct {
+	Name       string       `json:"name"`
+	Characters []*Character `json:"characters"`
+}
+
+// Account 一个账号及其账号通用的插件设置和服务器
+type Account struct {
+	Name           string           `json:"name"`
+	SavedVariables []SavedVariables `json:"saved_variables"`
+	Realms         []*Realm         `json:"realms"`
+}
+
+// Inventory WTF文件夹（或备份）中的账号、服务器、角色和插件设置
+type Inventory struct {
+	// 来源说明，例如备份名称
+	Source   string     `json:"source"`
+	Accounts []*Account `json:"accounts"`
+}
+
+// svDirs 保存插件设置的文件夹
+var svDirs = []string{"SavedVariables", "SavedVariablesPerCharacter"}
+
+// Inspect 遍历WTF文件夹（或备份）中的 Account 文件夹
+func Inspect(fsys fs.FS, source string) (*Inventory, error) {
+	inv := &Inventory{Source: source, Accounts: []*Account{}}
+
+	accounts, err := subDirs(fsys, "Account")
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return inv, nil
+		}
+		return nil, err
+	}
+
+	for _, accountName := range accounts {
+		accountDir := path.Join("Account", accountName)
+		account := &Account{Name: accountName, Realms: []*Realm{}}
+		if account.SavedVariables, err = savedVariables(fsys, accountDir); err != nil {
+			return nil, err
+		}
+
+		realms, err := subDirs(fsys, accountDir)
+		if err != nil {
+			return nil, err
+		}
+		for _, realmName := range realms {
+			if isSVDir(realmName) {
+				continue
+			}
+			realmDir := path.Join(accountDir, realmName)
+			realm := &Realm{Name: realmName, Characters: []*Character{}}
+
+			characters, err := subDirs(fsys, realmDir)
+			if err != nil {
+				return nil, err
+			}
+			for _, characterName := range characters {
+				character := &Character{Name: characterName}
+				if character.SavedVariables, err = savedVariables(fsys, path.Join(realmDir, characterName)); err != nil {
+					return nil, err
+				}
+				realm.Characters = append(realm.Characters, character)
+			}
+			account.Realms = append(account.Realms, realm)
+		}
+		inv.Accounts = append(inv.Accounts, account)
+	}
+	return inv, nil
+}
+
+// subDirs 返回文件夹中的子文件夹名称，按名称排序
+func subDirs(fsys fs.FS, dir string) ([]string, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// isSVDir 是否为保存插件设置的文件夹
+func isSVDir(name string) bool {
+	for _, dir := range svDirs {
+		if name == dir {
+			return true
+		}
+	}
+	return false
+}
+
+// savedVariables 列出账号或角色文件夹中的 SavedVariables 文件，按插件名称排序
+func savedVariables(fsys fs.FS, dir string) ([]SavedVariables, error) {
+	files := []SavedVariables{}
+	for _, svDir := range svDirs {
+		entries, err := fs.ReadDir(fsys, path.Join(dir, svDir))
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+
+		for _, entry := range entries {
+			if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".lua") {
+				continue
+			}
+			info, err := entry.Info()
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, SavedVariables{
+				Addon:        strings.TrimSuffix(entry.Name(), ".lua"),
+				Path:         path.Join(dir, svDir, entry.Name()),
+				PerCharacter: svDir == "SavedVariablesPerCharacter",
+				Size:         info.Size(),
+				ModTime:      info.ModTime(),
+			})
+		}
+	}
+
+	sort.SliceStable(files, func(i, j int) bool {
+		if !strings.EqualFold(files[i].Addon, files[j].Addon) {
+			return strings.ToLower(files[i].Addon) < strings.ToLower(files[j].Addon)
+		}
+		return !files[i].PerCharacter && files[j].PerCharacter
+	})
+	return files, nil
+}
+
+// WriteJSON 以JSON格式输出
+func (inv *Inventory) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(inv)
+}
+
+// WriteText 以树形结构输出
+func (inv *Inventory) WriteText(w io.Writer) error {
+	fmt.Fprintf(w, "%s\n", inv.Source)
+	if len(inv.Accounts) == 0 {
+		fmt.Fprintln(w, "  (没有找到账号)")
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, account := range inv.Accounts {
+		fmt.Fprintf(tw, "账号 %s (%d 个插件设置)\n", account.Name, len(account.SavedVariables))
+		writeSavedVariables(tw, "    ", account.SavedVariables)
+		for _, realm := range account.Realms {
+			fmt.Fprintf(tw, "  服务器 %s (%d 个角色)\n", realm.Name, len(realm.Characters))
+			for _, character := range realm.Characters {
+				fmt.Fprintf(tw, "    角色 %s (%d 个插件设置)\n", character.Name, len(character.SavedVariables))
+				writeSavedVariables(tw, "        ", character.SavedVariables)
+			}
+		}
+	}
+	return tw.Flush()
+}
+
+// writeSavedVariables 输出插件设置文件的名称、大小和修改时间
+func writeSavedVariables(w io.Writer, indent string, files []SavedVariables) {
+	for _, file := range files {
+		name := file.Addon
+		if file.PerCharacter {
+			name += " (PerCharacter)"
+		}
+		fmt.Fprintf(w, "%s%s\t%s\t%s\n", indent, name, progress.FormatBytes(file.Size), file.ModTime.Format("2006-01-02 15:04:05"))
+	}
+}
@@ -1,25 +1,42 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
 
 	"github.com/lizhening/WtfBackup/backup"
+	"github.com/lizhening/WtfBackup/clone"
 	"github.com/lizhening/WtfBackup/config"
+	"github.com/lizhening/WtfBackup/diff"
+	"github.com/lizhening/WtfBackup/inspect"
+	"github.com/lizhening/WtfBackup/pkg/detect"
 	"github.com/lizhening/WtfBackup/pkg/fileutil"
 	"github.com/lizhening/WtfBackup/pkg/logger"
+	"github.com/lizhening/WtfBackup/pkg/luasv"
+	"github.com/lizhening/WtfBackup/pkg/process"
+	"github.com/lizhening/WtfBackup/pkg/progress"
+	"github.com/lizhening/WtfBackup/pkg/retention"
+	"github.com/lizhening/WtfBackup/pkg/snapshot"
+	"github.com/lizhening/WtfBackup/pkg/wtf"
 	"github.com/lizhening/WtfBackup/restore"
+	"github.com/lizhening/WtfBackup/undo"
 )
 
-func main() {
-	// 初始化日志系统
-	log := logger.NewLogger(logger.LogLevelInfo, os.Stdout, "WTF-Backup")
-	logger.SetDefaultLogger(log)
+// version 程序版本，发布时通过 -ldflags "-X main.version=<版本>" 设置
+var version = "dev"
 
-	// 初始化文件操作器
-	fileOp := fileutil.NewDefaultFileOperator(32 * 1024) // 32KB buffer
+func main() {
+	// 初始化日志系统，解析子命令参数后会按 -v、-q、-log-level 和配置文件重新设置
+	logger.SetDefaultLogger(logger.NewLogger(logger.LogLevelInfo, os.Stdout, "WTF-Backup"))
 
 	// 先加载配置文件
 	configPath := config.DefaultConfigPath()
@@ -28,29 +45,147 @@ func main() {
 		logger.Error("加载配置文件失败: %v", err)
 		os.Exit(1)
 	}
+	// 初始化文件操作器
+	fileOp := fileutil.NewDefaultFileOperator(32*1024, cfg.Concurrency) // 32KB buffer
+
+	// 按下 Ctrl-C 时不再开始复制新的文件，并删除未完成的备份
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
 	// 创建子命令
 	backupCmd := flag.NewFlagSet("backup", flag.ExitOnError)
 	restoreCmd := flag.NewFlagSet("restore", flag.ExitOnError)
 	configCmd := flag.NewFlagSet("config", flag.ExitOnError)
+	verifyCmd := flag.NewFlagSet("verify", flag.ExitOnError)
+	listCmd := flag.NewFlagSet("list", flag.ExitOnError)
+	diffCmd := flag.NewFlagSet("diff", flag.ExitOnError)
+	undoCmd := flag.NewFlagSet("undo", flag.ExitOnError)
+	cloneCmd := flag.NewFlagSet("clone", flag.ExitOnError)
+	detectCmd := flag.NewFlagSet("detect", flag.ExitOnError)
+	inspectCmd := flag.NewFlagSet("inspect", flag.ExitOnError)
+	pruneCmd := flag.NewFlagSet("prune", flag.ExitOnError)
+	pinCmd := flag.NewFlagSet("pin", flag.ExitOnError)
+	unpinCmd := flag.NewFlagSet("unpin", flag.ExitOnError)
+	commands := []*flag.FlagSet{backupCmd, restoreCmd, configCmd, verifyCmd, listCmd, diffCmd, undoCmd,
+		cloneCmd, detectCmd, inspectCmd, pruneCmd, pinCmd, unpinCmd}
+
+	// 所有子命令共用的日志参数
+	var logOpts logOptions
+	for _, cmd := range commands {
+		logOpts.register(cmd)
+	}
 
 	// 备份命令参数 - 可选，如果不提供将使用配置文件中的设置
 	wtfPath := backupCmd.String("wtf", cfg.WtfPath, "WTF文件夹路径 (可选，默认使用配置文件)")
 	backupDir := backupCmd.String("backup", cfg.BackupDir, "备份保存的文件夹路径 (可选，默认使用配置文件)")
 	showProgress := backupCmd.Bool("progress", true, "显示进度条")
-	keepBackups := backupCmd.Int("keep", 5, "保留的备份数量")
+	progressDetail := backupCmd.Bool("progress-detail", false, "在进度条上方逐行显示已复制的文件")
+	backupProgressFormat := backupCmd.String("progress-format", "text", "进度的输出格式: text (终端进度条), json (每行一个 JSON 事件，输出到标准错误)")
+	keepBackups := backupCmd.Int("keep", 0, "保留的备份数量，覆盖配置文件中的保留数量，0 表示不清理旧备份 (可选，默认使用配置文件中的保留策略，没有设置时保留 5 个)")
+	backupIncremental := backupCmd.Bool("incremental", cfg.Incremental, "增量备份，未变化的文件硬链接到上一个备份 (仅文件夹格式)")
+	backupFormat := backupCmd.String("format", cfg.Format, "备份格式: dir, zip, tar.gz, tar.zst, repo (可选，默认使用配置文件)")
+	backupDryRun := backupCmd.Bool("dry-run", false, "试运行: 只列出将要创建、覆盖或删除的文件，不修改磁盘")
+	backupSource := backupCmd.String("source", "", "要备份的来源名称，all 表示所有来源 (可选，默认使用全局的WTF文件夹)")
+	backupPin := backupCmd.Bool("pin", false, "固定这个备份，清理旧备份时不会删除")
+	backupNote := backupCmd.String("note", "", "备份的备注，例如 \"11.0 版本更新前\" (可选)")
+	backupIfRunning := backupCmd.String("if-running", cfg.OnGameRunning, "游戏正在运行时的处理方式: warn (警告后继续), wait (等待游戏退出), abort (取消备份) (可选，默认使用配置文件，没有设置时为 warn)")
+	backupLabel := backupCmd.String("label", "", "备份的标签，恢复时可以使用 -from label:<标签> 选择这个备份 (可选)")
 
 	// 恢复命令参数
 	restoreWtfPath := restoreCmd.String("wtf", cfg.WtfPath, "要恢复到的WTF文件夹路径 (可选，默认使用配置文件)")
 	restoreBackupDir := restoreCmd.String("backup", cfg.BackupDir, "备份文件夹路径 (可选，默认使用配置文件)")
 	addonName := restoreCmd.String("addon", "", "要恢复的插件名称 (可选，如不提供则恢复配置中的所有插件)")
 	restoreShowProgress := restoreCmd.Bool("progress", true, "显示进度条")
+	restoreForce := restoreCmd.Bool("force", false, "即使备份中的SavedVariables文件可能已损坏也继续恢复")
+	restoreDryRun := restoreCmd.Bool("dry-run", false, "试运行: 只列出将要创建或覆盖的文件，不修改磁盘")
+	restoreAccount := restoreCmd.String("account", "", "只恢复匹配的账号，支持通配符 (可选)")
+	restoreRealm := restoreCmd.String("realm", "", "只恢复匹配的服务器上的角色，支持通配符 (可选)")
+	restoreCharacter := restoreCmd.String("character", "", "只恢复匹配的角色，支持通配符 (可选)")
+	restoreAccountWide := restoreCmd.Bool("account-wide", true, "恢复账号通用的设置 (Account/<账号>/SavedVariables)，指定 -realm 或 -character 时默认不恢复")
+	restoreCharacters := restoreCmd.Bool("characters", true, "恢复角色的设置 (Account/<账号>/<服务器>/<角色>)")
+	restoreSource := restoreCmd.String("source", "", "要恢复的来源名称，all 表示所有来源 (可选，默认使用全局的WTF文件夹)")
+	restoreAllowRunning := restoreCmd.Bool("allow-running", false, "游戏正在运行时仍然恢复 (游戏退出时可能会覆盖恢复的文件)")
+	restoreProgressFormat := restoreCmd.String("progress-format", "text", "进度的输出格式: text (终端进度条), json (每行一个 JSON 事件，输出到标准错误)")
+	restoreFrom := restoreCmd.String("from", "", "要恢复的备份: 时间戳或其前缀、序号(0或#0为最新)、备份名称、before:<日期> 或 label:<标签> (可选，默认最新的备份)")
+
+	// 列表命令参数
+	listBackupDir := listCmd.String("backup", cfg.BackupDir, "备份文件夹路径 (可选，默认使用配置文件)")
+	listSource := listCmd.String("source", "", "要列出的来源名称，all 表示所有来源 (可选，默认使用全局的备份文件夹)")
+
+	// 比较命令参数
+	diffWtfPath := diffCmd.String("wtf", cfg.WtfPath, "WTF文件夹路径，用于 live (可选，默认使用配置文件)")
+	diffBackupDir := diffCmd.String("backup", cfg.BackupDir, "备份文件夹路径 (可选，默认使用配置文件)")
+	diffAddon := diffCmd.String("addon", "", "要比较的插件名称 (可选，如不提供则比较配置中的所有插件)")
+	diffFrom := diffCmd.String("from", "1", "旧版本: 备份选择器或 live (当前WTF文件夹)")
+	diffTo := diffCmd.String("to", "0", "新版本: 备份选择器或 live (当前WTF文件夹)")
+	diffSource := diffCmd.String("source", "", "来源名称 (可选，默认使用全局的WTF文件夹)")
+
+	// 克隆命令参数
+	cloneWtfPath := cloneCmd.String("wtf", cfg.WtfPath, "WTF文件夹路径 (可选，默认使用配置文件)")
+	cloneBackupDir := cloneCmd.String("backup", cfg.BackupDir, "备份文件夹路径 (可选，默认使用配置文件)")
+	cloneFrom := cloneCmd.String("from", "", "来源角色: <账号>/<服务器>/<角色>")
+	cloneTo := cloneCmd.String("to", "", "目标角色: <账号>/<服务器>/<角色>")
+	cloneAddons := cloneCmd.String("addons", "", "只复制这些插件的设置 (多个插件用逗号分隔，可选)")
+	cloneFull := cloneCmd.Bool("full", false, "复制角色文件夹中的所有文件")
+	cloneBackupID := cloneCmd.String("backup-id", snapshot.Live, "读取设置的备份: live (当前WTF文件夹)、时间戳、序号或备份名称")
+	cloneForce := cloneCmd.Bool("force", false, "即使来源中的SavedVariables文件可能已损坏也继续复制")
+	cloneSource := cloneCmd.String("source", "", "来源名称 (可选，默认使用全局的WTF文件夹)")
+	cloneAllowRunning := cloneCmd.Bool("allow-running", false, "游戏正在运行时仍然克隆 (游戏退出时可能会覆盖复制的文件)")
+	cloneDryRun := cloneCmd.Bool("dry-run", false, "试运行: 只列出将要创建或覆盖的文件，不修改磁盘")
+
+	// 查看命令参数
+	inspectWtfPath := inspectCmd.String("wtf", cfg.WtfPath, "WTF文件夹路径，用于 live (可选，默认使用配置文件)")
+	inspectBackupDir := inspectCmd.String("backup", cfg.BackupDir, "备份文件夹路径 (可选，默认使用配置文件)")
+	inspectBackupID := inspectCmd.String("backup-id", snapshot.Live, "要查看的备份: live (当前WTF文件夹)、时间戳、序号或备份名称")
+	inspectSource := inspectCmd.String("source", "", "来源名称 (可选，默认使用全局的WTF文件夹)")
+	inspectJSON := inspectCmd.Bool("json", false, "以JSON格式输出")
+
+	// 清理命令参数
+	pruneBackupDir := pruneCmd.String("backup", cfg.BackupDir, "备份文件夹路径 (可选，默认使用配置文件)")
+	pruneSource := pruneCmd.String("source", "", "要清理的来源名称，all 表示所有来源 (可选，默认使用全局的备份文件夹)")
+	pruneKeep := pruneCmd.Int("keep", 0, "保留的备份数量，覆盖配置文件中的保留数量 (可选)")
+	pruneDryRun := pruneCmd.Bool("dry-run", false, "试运行: 只列出每个备份将被保留还是删除以及原因，不修改磁盘")
+	pruneProgressFormat := pruneCmd.String("progress-format", "text", "进度的输出格式: text, json (每行一个 JSON 事件，输出到标准错误)")
+
+	// 固定命令参数
+	pinBackupDir := pinCmd.String("backup", cfg.BackupDir, "备份文件夹路径 (可选，默认使用配置文件)")
+	pinSource := pinCmd.String("source", "", "来源名称 (可选，默认使用全局的备份文件夹)")
+	pinBackupID := pinCmd.String("backup-id", "", "要固定的备份: 时间戳、序号、备份名称或 before:<日期> (可选，默认最新的备份)")
+	pinNote := pinCmd.String("note", "", "备份的备注 (可选)")
+	unpinBackupDir := unpinCmd.String("backup", cfg.BackupDir, "备份文件夹路径 (可选，默认使用配置文件)")
+	unpinSource := unpinCmd.String("source", "", "来源名称 (可选，默认使用全局的备份文件夹)")
+	unpinBackupID := unpinCmd.String("backup-id", "", "要取消固定的备份: 时间戳、序号、备份名称或 before:<日期> (可选，默认最新的备份)")
+
+	// 检测命令参数
+	detectUse := detectCmd.String("use", "", "将指定版本的WTF文件夹保存到配置文件，例如 retail、classic、classic_era")
+
+	// 撤销命令参数
+	undoBackupDir := undoCmd.String("backup", cfg.BackupDir, "备份文件夹路径 (可选，默认使用配置文件)")
+	undoID := undoCmd.String("id", "", "要撤销的恢复: 快照ID或序号(0为最新) (可选，默认最近一次尚未撤销的恢复)")
+	undoSource := undoCmd.String("source", "", "来源名称 (可选，默认使用全局的WTF文件夹)")
+	undoAllowRunning := undoCmd.Bool("allow-running", false, "游戏正在运行时仍然撤销 (游戏退出时可能会覆盖还原的文件)")
+	undoList := undoCmd.Bool("list", false, "列出所有可以撤销的恢复操作")
+
+	// 校验命令参数
+	verifyBackupDir := verifyCmd.String("backup", cfg.BackupDir, "备份文件夹路径 (可选，默认使用配置文件)")
+	verifySource := verifyCmd.String("source", "", "来源名称 (可选，默认使用全局的WTF文件夹)")
+	verifyBackupID := verifyCmd.String("backup-id", "", "要校验的备份: 时间戳、序号、备份名称或 before:<日期> (可选，如不提供则校验所有备份)")
 
 	// 配置命令参数
 	configWtfPath := configCmd.String("wtf", "", "设置WTF文件夹路径")
 	configBackupDir := configCmd.String("backup", "", "设置备份文件夹路径")
 	configAddAddons := configCmd.String("add-addons", "", "添加插件到恢复列表 (多个插件用逗号分隔)")
 	configRemoveAddons := configCmd.String("remove-addons", "", "从恢复列表移除插件 (多个插件用逗号分隔)")
+	configFormat := configCmd.String("format", "", "设置备份格式: dir, zip, tar.gz, tar.zst, repo")
+	configIncremental := configCmd.Bool("incremental", false, "设置是否增量备份 (仅文件夹格式)，-incremental=false 表示关闭")
+	configKeep := configCmd.Int("keep", 0, "设置保留的备份数量")
+	configConcurrency := configCmd.Int("concurrency", 0, "设置复制文件夹时同时复制的文件数量")
+	configOnGameRunning := configCmd.String("on-game-running", "", "设置备份时游戏正在运行的处理方式: warn, wait, abort")
+	configRetention := configCmd.String("retention", "", "设置保留策略，例如 hours=24,daily=7,weekly=4,monthly=6，none 表示清除")
+	var configAddSources stringList
+	configCmd.Var(&configAddSources, "add-source", "添加或更新来源: <名称>=<WTF文件夹路径>，例如 classic=/path/to/_classic_/WTF (可以指定多次)")
+	configRemoveSource := configCmd.String("remove-source", "", "移除来源")
+	configLogFile := configCmd.String("log-file", "", "设置日志文件路径，none 表示不写入日志文件")
 	configShowFlag := configCmd.Bool("show", false, "显示当前配置")
 
 	// 检查参数
@@ -59,10 +194,37 @@ func main() {
 		os.Exit(1)
 	}
 
+	// 解析子命令参数，然后按参数和配置文件设置日志
+	var parsed *flag.FlagSet
+	for _, cmd := range commands {
+		if cmd.Name() == os.Args[1] {
+			cmd.Parse(os.Args[2:])
+			parsed = cmd
+		}
+	}
+	closeLog := setupLogging(cfg, logOpts)
+	defer closeLog()
+
+	// -wtf 只替换全局的WTF文件夹，与 -source 同时使用时无法确定作用于哪个WTF文件夹，
+	// 也不能把来源的WTF文件夹保存为全局设置
+	if parsed != nil && isFlagSet(parsed, "wtf") && isFlagSet(parsed, "source") {
+		logger.Error("-wtf 不能与 -source 同时使用，来源的WTF文件夹可以用 config -add-source <名称>=<WTF文件夹路径> 修改")
+		os.Exit(1)
+	}
+
+	// 自动检测游戏安装需要扫描磁盘，只在用到WTF文件夹且没有指定 -wtf 的命令中进行
+	switch os.Args[1] {
+	case "backup", "restore", "diff", "clone", "inspect", "config":
+		if !isFlagSet(parsed, "wtf") && cfg.DetectWtfPath() {
+			logger.Info("未配置WTF文件夹，使用自动检测到的: %s", cfg.WtfPath)
+		}
+	}
+
 	// 根据子命令执行不同的功能
 	switch os.Args[1] {
 	case "backup":
-		backupCmd.Parse(os.Args[2:])
+		progress.SetShowDetail(*progressDetail)
+		setProgressFormat(*backupProgressFormat)
 		// 更新配置
 		if *wtfPath != "" && *wtfPath != cfg.WtfPath {
 			cfg.WtfPath = config.NormalizePath(*wtfPath)
@@ -70,37 +232,91 @@ func main() {
 		if *backupDir != "" && *backupDir != cfg.BackupDir {
 			cfg.BackupDir = config.NormalizePath(*backupDir)
 		}
-
-		// 保存更新后的配置
-		if err := config.SaveConfig(cfg, configPath); err != nil {
-			logger.Error("保存配置文件失败: %v", err)
+		if _, err := snapshot.ParseFormat(*backupFormat); err != nil {
+			logger.Error("%v", err)
+			os.Exit(1)
 		}
-
-		if cfg.WtfPath == "" || cfg.BackupDir == "" {
-			logger.Error("必须提供WTF文件夹路径和备份路径，可以通过命令行参数或配置文件设置")
-			backupCmd.PrintDefaults()
+		if _, err := process.ParsePolicy(*backupIfRunning); err != nil {
+			logger.Error("%v", err)
 			os.Exit(1)
 		}
 
-		// 执行备份
-		logger.Info("开始备份WTF文件夹...")
-		err := backup.BackupWtf(*cfg, fileOp, *showProgress)
-		if err != nil {
-			logger.Error("备份失败: %v", err)
-			os.Exit(1)
+		// 试运行时只记录操作，不修改磁盘（包括配置文件）
+		var backupOp fileutil.FileOperator = fileOp
+		var dryRun *fileutil.DryRunOperator
+		if *backupDryRun {
+			dryRun = fileutil.NewDryRunOperator(fileOp)
+			backupOp = dryRun
+			*showProgress = false
+		} else if err := config.SaveConfig(cfg, configPath); err != nil {
+			// 保存更新后的配置
+			logger.Error("保存配置文件失败: %v", err)
 		}
-		logger.Info("备份成功完成!")
 
-		// 清理旧备份
-		if *keepBackups > 0 {
-			logger.Info("清理旧备份...")
-			if err := fileOp.CleanOldBackups(cfg.BackupDir, *keepBackups); err != nil {
+		targets := selectSources(cfg, *backupSource)
+		failed := false
+		for _, target := range targets {
+			// 按下 Ctrl-C 后不再处理其他来源
+			if ctx.Err() != nil {
+				break
+			}
+			if target.SourceName != "" {
+				logger.Info("== 来源 %s ==", target.SourceName)
+			}
+			if target.WtfPath == "" || target.BackupDir == "" {
+				logger.Error("必须提供WTF文件夹路径和备份路径，可以通过命令行参数或配置文件设置")
+				backupCmd.PrintDefaults()
+				os.Exit(1)
+			}
+
+			// 执行备份，-format、-incremental 和 -if-running 只对这次备份有效，不保存到配置文件
+			target.Format = *backupFormat
+			target.Incremental = *backupIncremental
+			target.OnGameRunning = *backupIfRunning
+			logger.Info("开始备份WTF文件夹...")
+			err := backup.BackupWtf(ctx, target, backupOp, backup.Options{
+				ShowProgress: *showProgress,
+				Pin:          *backupPin,
+				Label:        *backupLabel,
+				Note:         *backupNote,
+				Version:      version,
+			})
+			if err != nil {
+				logger.Error("备份失败: %v", err)
+				// 继续备份其他来源
+				failed = true
+				continue
+			}
+			if dryRun == nil {
+				logger.Info("备份成功完成!")
+			}
+
+			// 按配置文件中的保留策略清理旧备份，命令行中的 -keep 覆盖保留数量，-keep 0 表示不清理
+			if isFlagSet(backupCmd, "keep") && *keepBackups <= 0 {
+				continue
+			}
+			policy := target.RetentionPolicy(*keepBackups)
+			if dryRun == nil {
+				logger.Info("按保留策略清理旧备份 (%s)...", policy)
+			}
+			if err := backup.Prune(target.BackupDir, policy, backupOp); err != nil {
 				logger.Error("清理旧备份失败: %v", err)
 			}
 		}
 
+		if dryRun != nil {
+			printDryRunReport(dryRun)
+		}
+		if failed {
+			os.Exit(1)
+		}
+
 	case "restore":
-		restoreCmd.Parse(os.Args[2:])
+		setProgressFormat(*restoreProgressFormat)
+		if progress.Enabled() {
+			// 每个恢复的文件都会发布事件，不再显示单个文件的进度条
+			*restoreShowProgress = false
+		}
 		// 更新配置
 		if *restoreWtfPath != "" && *restoreWtfPath != cfg.WtfPath {
 			cfg.WtfPath = config.NormalizePath(*restoreWtfPath)
@@ -109,48 +325,523 @@ func main() {
 			cfg.BackupDir = config.NormalizePath(*restoreBackupDir)
 		}
 
-		// 保存更新后的配置
-		if err := config.SaveConfig(cfg, configPath); err != nil {
+		// 试运行时只记录操作，不修改磁盘（包括配置文件）
+		var restoreOp fileutil.FileOperator = fileOp
+		var dryRun *fileutil.DryRunOperator
+		if *restoreDryRun {
+			dryRun = fileutil.NewDryRunOperator(fileOp)
+			restoreOp = dryRun
+			*restoreShowProgress = false
+		} else if err := config.SaveConfig(cfg, configPath); err != nil {
+			// 保存更新后的配置
 			logger.Error("保存配置文件失败: %v", err)
 		}
 
-		if cfg.WtfPath == "" || cfg.BackupDir == "" {
-			logger.Error("必须提供WTF文件夹路径和备份路径，可以通过命令行参数或配置文件设置")
-			restoreCmd.PrintDefaults()
+		restoreOpts := restore.Options{
+			From:         *restoreFrom,
+			ShowProgress: *restoreShowProgress,
+			Force:        *restoreForce,
+			AllowRunning: *restoreAllowRunning,
+			Scope: wtf.Scope{
+				Account:       *restoreAccount,
+				Realm:         *restoreRealm,
+				Character:     *restoreCharacter,
+				NoAccountWide: !*restoreAccountWide,
+				// 指定服务器或角色时，只有显式指定 -account-wide 才恢复账号通用的设置
+				AccountWide:  *restoreAccountWide && isFlagSet(restoreCmd, "account-wide"),
+				NoCharacters: !*restoreCharacters,
+			},
+		}
+
+		targets := selectSources(cfg, *restoreSource)
+		failed := false
+		for _, target := range targets {
+			// 按下 Ctrl-C 后不再处理其他来源
+			if ctx.Err() != nil {
+				break
+			}
+			if target.SourceName != "" {
+				logger.Info("== 来源 %s ==", target.SourceName)
+			}
+			if target.WtfPath == "" || target.BackupDir == "" {
+				logger.Error("必须提供WTF文件夹路径和备份路径，可以通过命令行参数或配置文件设置")
+				restoreCmd.PrintDefaults()
+				os.Exit(1)
+			}
+
+			// 如果提供了插件名，则只恢复该插件
+			if *addonName != "" {
+				logger.Info("开始恢复插件 %s...", *addonName)
+				err := restore.RestoreAddon(ctx, target, *addonName, restoreOp, restoreOpts)
+				if err != nil {
+					logger.Error("恢复插件 %s 失败: %v", *addonName, err)
+					failed = true
+					continue
+				}
+				if dryRun == nil {
+					logger.Info("插件 %s 恢复成功完成!", *addonName)
+				}
+			} else if len(target.Addons) > 0 {
+				// 恢复配置中的所有插件，作为一次恢复操作，可以一起撤销
+				logger.Info("将恢复配置中的 %d 个插件", len(target.Addons))
+				err := restore.RestoreAddons(ctx, target, target.Addons, restoreOp, restoreOpts)
+				if err != nil {
+					logger.Error("恢复插件失败: %v", err)
+					failed = true
+					continue
+				}
+				if dryRun == nil {
+					logger.Info("所有插件恢复操作完成!")
+				}
+			} else {
+				logger.Error("必须提供要恢复的插件名称，或在配置文件中配置插件列表")
+				restoreCmd.PrintDefaults()
+				os.Exit(1)
+			}
+		}
+
+		if dryRun != nil {
+			printDryRunReport(dryRun)
+		}
+		if failed {
 			os.Exit(1)
 		}
 
-		// 如果提供了插件名，则只恢复该插件
-		if *addonName != "" {
-			logger.Info("开始恢复插件 %s...", *addonName)
-			err := restore.RestoreAddon(*cfg, *addonName, fileOp, *restoreShowProgress)
-			if err != nil {
-				logger.Error("恢复插件 %s 失败: %v", *addonName, err)
+	case "list":
+		if *listBackupDir == "" {
+			logger.Error("必须提供备份路径，可以通过命令行参数或配置文件设置")
+			listCmd.PrintDefaults()
+			os.Exit(1)
+		}
+
+		listCfg := *cfg
+		listCfg.BackupDir = config.NormalizePath(*listBackupDir)
+		for _, target := range selectSources(&listCfg, *listSource) {
+			if target.SourceName != "" {
+				fmt.Printf("\n== 来源 %s (%s) ==\n", target.SourceName, target.BackupDir)
+			}
+			backups, err := snapshot.List(target.BackupDir)
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				logger.Error("%v", err)
 				os.Exit(1)
 			}
-			logger.Info("插件 %s 恢复成功完成!", *addonName)
-		} else if len(cfg.Addons) > 0 {
-			// 恢复配置中的所有插件
-			logger.Info("将恢复配置中的 %d 个插件", len(cfg.Addons))
-			for _, addon := range cfg.Addons {
-				logger.Info("恢复插件: %s", addon)
-				err := restore.RestoreAddon(*cfg, addon, fileOp, *restoreShowProgress)
+			if len(backups) == 0 {
+				logger.Info("没有找到备份")
+				continue
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "序号\t备份名称\t时间\t格式\t大小\t文件数\t状态\t标签\t备注\t包含的插件")
+			for i, b := range backups {
+				summary, err := backup.Summarize(b, target.Addons)
 				if err != nil {
-					logger.Error("恢复插件 %s 失败: %v", addon, err)
-					// 继续恢复其他插件
-				} else {
-					logger.Info("插件 %s 恢复成功!", addon)
+					fmt.Fprintf(w, "%d\t%s\t%s\t%s\t-\t-\t-\t-\t-\t读取失败: %v\n", i, b.Name, b.Time.Format("2006-01-02 15:04:05"), b.Format, err)
+					continue
+				}
+				addons := "-"
+				if len(summary.Addons) > 0 {
+					addons = strings.Join(summary.Addons, ", ")
 				}
+				var status []string
+				if summary.Meta.Pinned {
+					status = append(status, "已固定")
+				}
+				if summary.Meta.Suspect {
+					status = append(status, "可疑")
+				}
+				if summary.Meta.GameRunning {
+					status = append(status, "游戏运行中")
+				}
+				if len(status) == 0 {
+					status = append(status, "-")
+				}
+				fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%d\t%s\t%s\t%s\t%s\n", i, b.Name, b.Time.Format("2006-01-02 15:04:05"), b.Format,
+					progress.FormatBytes(summary.Size), summary.Files, strings.Join(status, ", "),
+					orDash(summary.Meta.Label), orDash(summary.Meta.Note), addons)
+			}
+			w.Flush()
+		}
+
+	case "prune":
+		setProgressFormat(*pruneProgressFormat)
+		if *pruneBackupDir == "" {
+			logger.Error("必须提供备份路径，可以通过命令行参数或配置文件设置")
+			pruneCmd.PrintDefaults()
+			os.Exit(1)
+		}
+
+		pruneCfg := *cfg
+		pruneCfg.BackupDir = config.NormalizePath(*pruneBackupDir)
+		failed := false
+		for _, target := range selectSources(&pruneCfg, *pruneSource) {
+			if target.SourceName != "" {
+				fmt.Printf("\n== 来源 %s (%s) ==\n", target.SourceName, target.BackupDir)
+			}
+			policy := target.RetentionPolicy(*pruneKeep)
+			if !*pruneDryRun {
+				logger.Info("按保留策略清理旧备份 (%s)...", policy)
+				if err := backup.Prune(target.BackupDir, policy, fileOp); err != nil {
+					logger.Error("清理旧备份失败: %v", err)
+					failed = true
+				}
+				continue
+			}
+
+			backups, err := snapshot.List(target.BackupDir)
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				logger.Error("%v", err)
+				failed = true
+				continue
+			}
+			if len(backups) == 0 {
+				logger.Info("没有找到备份")
+				continue
+			}
+
+			fmt.Printf("保留策略: %s\n", policy)
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "操作\t备份名称\t时间\t标签\t原因")
+			removed := 0
+			for _, d := range retention.Apply(backups, policy, time.Now()) {
+				action := "保留"
+				if !d.Keep {
+					action = "删除"
+					removed++
+				}
+				label := ""
+				if d.Meta != nil {
+					label = d.Meta.Label
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", action, d.Backup.Name, d.Backup.Time.Format("2006-01-02 15:04:05"), orDash(label), d.Reason)
+			}
+			w.Flush()
+			fmt.Printf("\n共 %d 个备份，将保留 %d 个，删除 %d 个\n", len(backups), len(backups)-removed, removed)
+		}
+		if failed {
+			os.Exit(1)
+		}
+
+	case "pin", "unpin":
+		pinning := os.Args[1] == "pin"
+		cmd, dir, source, id := pinCmd, pinBackupDir, pinSource, pinBackupID
+		if !pinning {
+			cmd, dir, source, id = unpinCmd, unpinBackupDir, unpinSource, unpinBackupID
+		}
+		if *dir == "" {
+			logger.Error("必须提供备份路径，可以通过命令行参数或配置文件设置")
+			cmd.PrintDefaults()
+			os.Exit(1)
+		}
+
+		pinCfg := *cfg
+		pinCfg.BackupDir = config.NormalizePath(*dir)
+		backups, err := snapshot.List(selectSource(pinCfg, *source).BackupDir)
+		if err != nil {
+			logger.Error("%v", err)
+			os.Exit(1)
+		}
+		b, err := snapshot.Select(backups, *id)
+		if err != nil {
+			logger.Error("%v", err)
+			os.Exit(1)
+		}
+
+		if pinning {
+			if err := backup.Pin(b, *pinNote); err != nil {
+				logger.Error("固定备份失败: %v", err)
+				os.Exit(1)
+			}
+			logger.Info("已固定备份 %s，清理旧备份时不会删除", b.Name)
+		} else {
+			if err := backup.Unpin(b); err != nil {
+				logger.Error("取消固定备份失败: %v", err)
+				os.Exit(1)
+			}
+			logger.Info("已取消固定备份 %s", b.Name)
+		}
+
+	case "diff":
+		diffCfg := *cfg
+		if *diffWtfPath != "" {
+			diffCfg.WtfPath = config.NormalizePath(*diffWtfPath)
+		}
+		diffCfg.BackupDir = config.NormalizePath(*diffBackupDir)
+		diffCfg = selectSource(diffCfg, *diffSource)
+
+		addons := diffCfg.Addons
+		if *diffAddon != "" {
+			addons = []string{*diffAddon}
+		}
+		if len(addons) == 0 {
+			logger.Error("必须提供要比较的插件名称，或在配置文件中配置插件列表")
+			diffCmd.PrintDefaults()
+			os.Exit(1)
+		}
+
+		from, err := snapshot.OpenSource(diffCfg.WtfPath, diffCfg.BackupDir, *diffFrom)
+		if err != nil {
+			logger.Error("打开 %s 失败: %v", *diffFrom, err)
+			os.Exit(1)
+		}
+		defer from.Close()
+		to, err := snapshot.OpenSource(diffCfg.WtfPath, diffCfg.BackupDir, *diffTo)
+		if err != nil {
+			logger.Error("打开 %s 失败: %v", *diffTo, err)
+			os.Exit(1)
+		}
+		defer to.Close()
+
+		fmt.Printf("比较 %s -> %s\n", from.Name, to.Name)
+		for _, addon := range addons {
+			diffs, err := diff.DiffAddon(from, to, addon)
+			if err != nil {
+				logger.Error("比较插件 %s 失败: %v", addon, err)
+				continue
+			}
+			fmt.Printf("\n== %s ==\n", addon)
+			if len(diffs) == 0 {
+				fmt.Println("  (没有找到配置文件)")
+			}
+			for _, d := range diffs {
+				switch {
+				case d.Status == diff.FileAdded:
+					fmt.Printf("+ %s (新增文件)\n", d.Path)
+				case d.Status == diff.FileRemoved:
+					fmt.Printf("- %s (删除文件)\n", d.Path)
+				case d.Err != nil:
+					fmt.Printf("! %s: %v\n", d.Path, d.Err)
+				case len(d.Changes) == 0:
+					fmt.Printf("  %s (无变化)\n", d.Path)
+				default:
+					fmt.Printf("~ %s (%d 处变化)\n", d.Path, len(d.Changes))
+					for _, c := range d.Changes {
+						switch c.Kind {
+						case luasv.Added:
+							fmt.Printf("    + %s = %s\n", c.Path, luasv.Summary(c.New))
+						case luasv.Removed:
+							fmt.Printf("    - %s = %s\n", c.Path, luasv.Summary(c.Old))
+						default:
+							fmt.Printf("    ~ %s: %s -> %s\n", c.Path, luasv.Summary(c.Old), luasv.Summary(c.New))
+						}
+					}
+				}
+			}
+		}
+
+	case "clone":
+		cloneCfg := *cfg
+		if *cloneWtfPath != "" {
+			cloneCfg.WtfPath = config.NormalizePath(*cloneWtfPath)
+		}
+		cloneCfg.BackupDir = config.NormalizePath(*cloneBackupDir)
+		cloneCfg = selectSource(cloneCfg, *cloneSource)
+		if cloneCfg.WtfPath == "" || cloneCfg.BackupDir == "" {
+			logger.Error("必须提供WTF文件夹路径和备份路径，可以通过命令行参数或配置文件设置")
+			cloneCmd.PrintDefaults()
+			os.Exit(1)
+		}
+
+		from, err := wtf.ParseCharacter(*cloneFrom)
+		if err != nil {
+			logger.Error("-from: %v", err)
+			os.Exit(1)
+		}
+		to, err := wtf.ParseCharacter(*cloneTo)
+		if err != nil {
+			logger.Error("-to: %v", err)
+			os.Exit(1)
+		}
+
+		cloneOpts := clone.Options{
+			Source:       *cloneBackupID,
+			Full:         *cloneFull,
+			Force:        *cloneForce,
+			AllowRunning: *cloneAllowRunning,
+		}
+		for _, addon := range strings.Split(*cloneAddons, ",") {
+			if addon = strings.TrimSpace(addon); addon != "" {
+				cloneOpts.Addons = append(cloneOpts.Addons, addon)
 			}
-			logger.Info("所有插件恢复操作完成!")
+		}
+
+		var cloneOp fileutil.FileOperator = fileOp
+		var dryRun *fileutil.DryRunOperator
+		if *cloneDryRun {
+			dryRun = fileutil.NewDryRunOperator(fileOp)
+			cloneOp = dryRun
+		}
+
+		if err := clone.CloneCharacter(ctx, cloneCfg, from, to, cloneOp, cloneOpts); err != nil {
+			logger.Error("克隆失败: %v", err)
+			os.Exit(1)
+		}
+		if dryRun != nil {
+			printDryRunReport(dryRun)
+		} else {
+			logger.Info("克隆成功完成!")
+		}
+
+	case "inspect":
+		inspectCfg := *cfg
+		if *inspectWtfPath != "" {
+			inspectCfg.WtfPath = config.NormalizePath(*inspectWtfPath)
+		}
+		inspectCfg.BackupDir = config.NormalizePath(*inspectBackupDir)
+		inspectCfg = selectSource(inspectCfg, *inspectSource)
+
+		src, err := snapshot.OpenSource(inspectCfg.WtfPath, inspectCfg.BackupDir, *inspectBackupID)
+		if err != nil {
+			logger.Error("打开 %s 失败: %v", *inspectBackupID, err)
+			os.Exit(1)
+		}
+		defer src.Close()
+
+		inv, err := inspect.Inspect(src, src.Name)
+		if err != nil {
+			logger.Error("读取 %s 失败: %v", src.Name, err)
+			os.Exit(1)
+		}
+		if *inspectJSON {
+			err = inv.WriteJSON(os.Stdout)
 		} else {
-			logger.Error("必须提供要恢复的插件名称，或在配置文件中配置插件列表")
-			restoreCmd.PrintDefaults()
+			err = inv.WriteText(os.Stdout)
+		}
+		if err != nil {
+			logger.Error("%v", err)
+			os.Exit(1)
+		}
+
+	case "detect":
+		installs := detect.Detect()
+		if len(installs) == 0 {
+			logger.Info("没有在常见的安装位置找到魔兽世界，请使用 config -wtf 手动设置WTF文件夹路径")
+			break
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "版本\t文件夹\tWTF文件夹\t状态")
+		for _, install := range installs {
+			status := "已存在"
+			if !install.HasWTF {
+				status = "未创建 (游戏尚未运行过)"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", string(install.Flavor), install.Flavor, install.WtfPath, status)
+		}
+		w.Flush()
+
+		if *detectUse == "" {
+			break
+		}
+		flavor, ok := detect.ParseFlavor(*detectUse)
+		if !ok {
+			logger.Error("未知的游戏版本: %s", *detectUse)
+			os.Exit(1)
+		}
+		var selected *detect.Installation
+		for i := range installs {
+			if installs[i].Flavor == flavor {
+				selected = &installs[i]
+				break
+			}
+		}
+		if selected == nil {
+			logger.Error("没有找到 %s (%s)", string(flavor), flavor)
+			os.Exit(1)
+		}
+		cfg.WtfPath = selected.WtfPath
+		if err := config.SaveConfig(cfg, configPath); err != nil {
+			logger.Error("保存配置文件失败: %v", err)
+			os.Exit(1)
+		}
+		logger.Info("已将WTF文件夹设置为: %s", cfg.WtfPath)
+
+	case "undo":
+		if *undoBackupDir == "" {
+			logger.Error("必须提供备份路径，可以通过命令行参数或配置文件设置")
+			undoCmd.PrintDefaults()
+			os.Exit(1)
+		}
+
+		undoCfg := *cfg
+		undoCfg.BackupDir = config.NormalizePath(*undoBackupDir)
+		snapshots, err := undo.List(selectSource(undoCfg, *undoSource).BackupDir)
+		if err != nil {
+			logger.Error("%v", err)
+			os.Exit(1)
+		}
+
+		if *undoList {
+			if len(snapshots) == 0 {
+				logger.Info("没有可以撤销的恢复操作")
+				break
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "序号\t快照ID\t时间\t文件数\t状态\t操作")
+			for i, s := range snapshots {
+				status := "-"
+				if s.Undone != nil {
+					status = "已撤销"
+				}
+				fmt.Fprintf(w, "%d\t%s\t%s\t%d\t%s\t%s\n", i, s.ID, s.Created.Format("2006-01-02 15:04:05"), len(s.Files), status, s.Action)
+			}
+			w.Flush()
+			break
+		}
+
+		s, err := undo.Select(snapshots, *undoID)
+		if err != nil {
+			logger.Error("%v", err)
+			os.Exit(1)
+		}
+		if s.Undone != nil {
+			logger.Warn("快照 %s 已于 %s 撤销过，将再次还原", s.ID, s.Undone.Format("2006-01-02 15:04:05"))
+		}
+		if err := process.CheckBeforeWrite(nil, *undoAllowRunning); err != nil {
+			logger.Error("%v", err)
+			os.Exit(1)
+		}
+		logger.Info("撤销: %s (%s)", s.Action, s.Created.Format("2006-01-02 15:04:05"))
+		if err := undo.Undo(ctx, s, fileOp); err != nil {
+			logger.Error("撤销失败: %v", err)
+			os.Exit(1)
+		}
+		logger.Info("撤销成功完成!")
+
+	case "verify":
+		if *verifyBackupDir == "" {
+			logger.Error("必须提供备份路径，可以通过命令行参数或配置文件设置")
+			verifyCmd.PrintDefaults()
+			os.Exit(1)
+		}
+		verifyCfg := *cfg
+		verifyCfg.BackupDir = config.NormalizePath(*verifyBackupDir)
+		backupDirPath := selectSource(verifyCfg, *verifySource).BackupDir
+
+		backups, err := snapshot.List(backupDirPath)
+		if err != nil {
+			logger.Error("%v", err)
+			os.Exit(1)
+		}
+		if *verifyBackupID != "" && len(backups) > 0 {
+			b, err := snapshot.Select(backups, *verifyBackupID)
+			if err != nil {
+				logger.Error("%v", err)
+				os.Exit(1)
+			}
+			backups = []snapshot.Backup{b}
+		}
+		if len(backups) == 0 {
+			logger.Error("没有找到备份")
+			os.Exit(1)
+		}
+
+		// 校验所有备份时跳过没有清单的旧备份，指定的备份没有清单时报错
+		if err := backup.VerifyBackups(backups, *verifyBackupID == ""); err != nil {
+			logger.Error("%v", err)
 			os.Exit(1)
 		}
 
 	case "config":
-		configCmd.Parse(os.Args[2:])
 
 		// 更新WTF路径
 		if *configWtfPath != "" {
@@ -164,6 +855,110 @@ func main() {
 			logger.Info("已设置备份路径: %s", cfg.BackupDir)
 		}
 
+		// 更新备份格式
+		if *configFormat != "" {
+			if _, err := snapshot.ParseFormat(*configFormat); err != nil {
+				logger.Error("%v", err)
+				os.Exit(1)
+			}
+			cfg.Format = *configFormat
+			logger.Info("已设置备份格式: %s", cfg.Format)
+		}
+
+		// 更新增量备份
+		if isFlagSet(configCmd, "incremental") {
+			cfg.Incremental = *configIncremental
+			if cfg.Incremental {
+				logger.Info("已开启增量备份")
+			} else {
+				logger.Info("已关闭增量备份")
+			}
+		}
+
+		// 更新保留数量
+		if *configKeep > 0 {
+			cfg.Keep = *configKeep
+			logger.Info("已设置保留的备份数量: %d", cfg.Keep)
+		}
+
+		// 更新同时复制的文件数量
+		if *configConcurrency > 0 {
+			cfg.Concurrency = *configConcurrency
+			logger.Info("已设置同时复制的文件数量: %d", cfg.Concurrency)
+		}
+
+		// 更新游戏正在运行时的处理方式
+		if *configOnGameRunning != "" {
+			policy, err := process.ParsePolicy(*configOnGameRunning)
+			if err != nil {
+				logger.Error("%v", err)
+				os.Exit(1)
+			}
+			cfg.OnGameRunning = string(policy)
+			logger.Info("已设置备份时游戏正在运行的处理方式: %s", policy)
+		}
+
+		// 更新保留策略
+		if *configRetention != "" {
+			policy, err := retention.Parse(*configRetention)
+			if err != nil {
+				logger.Error("%v", err)
+				os.Exit(1)
+			}
+			cfg.Retention = policy
+			logger.Info("已设置保留策略: %s", policy)
+		}
+
+		// 更新日志文件
+		if *configLogFile != "" {
+			if *configLogFile == "none" {
+				cfg.Log.File = ""
+				logger.Info("已关闭日志文件")
+			} else {
+				cfg.Log.File = config.NormalizePath(*configLogFile)
+				logger.Info("已设置日志文件: %s", cfg.Log.File)
+			}
+		}
+
+		// 添加或更新来源
+		for _, addSource := range configAddSources {
+			name, path, ok := strings.Cut(addSource, "=")
+			name = strings.TrimSpace(name)
+			if !ok || strings.TrimSpace(path) == "" {
+				logger.Error("-add-source 的格式应为 <名称>=<WTF文件夹路径>")
+				os.Exit(1)
+			}
+			if err := config.ValidateSourceName(name); err != nil {
+				logger.Error("%v", err)
+				os.Exit(1)
+			}
+			path = config.NormalizePath(strings.TrimSpace(path))
+			if source, ok := cfg.FindSource(name); ok {
+				source.WtfPath = path
+				logger.Info("已更新来源 %s: %s", name, path)
+			} else {
+				cfg.Sources = append(cfg.Sources, config.Source{Name: name, WtfPath: path})
+				logger.Info("已添加来源 %s: %s", name, path)
+			}
+		}
+
+		// 移除来源
+		if *configRemoveSource != "" {
+			removed := false
+			for i, source := range cfg.Sources {
+				if source.Name == *configRemoveSource {
+					cfg.Sources = append(cfg.Sources[:i], cfg.Sources[i+1:]...)
+					removed = true
+					break
+				}
+			}
+			if removed {
+				logger.Info("已移除来源: %s (已有的备份不会被删除)", *configRemoveSource)
+			} else {
+				logger.Warn("没有名为 %s 的来源", *configRemoveSource)
+			}
+		}
+
 		// 添加插件
 		if *configAddAddons != "" {
 			addons := strings.Split(*configAddAddons, ",")
@@ -218,11 +1013,41 @@ func main() {
 		}
 
 		// 显示当前配置
-		if *configShowFlag || (*configWtfPath == "" && *configBackupDir == "" && *configAddAddons == "" && *configRemoveAddons == "") {
+		if *configShowFlag || configCmd.NFlag() == 0 {
 			logger.Info("\n当前配置:")
 			logger.Info("配置文件路径: %s", configPath)
-			logger.Info("WTF文件夹路径: %s", cfg.WtfPath)
+			if cfg.WtfPathDetected {
+				logger.Info("WTF文件夹路径: %s (自动检测)", cfg.WtfPath)
+			} else {
+				logger.Info("WTF文件夹路径: %s", cfg.WtfPath)
+			}
 			logger.Info("备份文件夹路径: %s", cfg.BackupDir)
+			if cfg.Format != "" {
+				logger.Info("备份格式: %s", cfg.Format)
+			} else {
+				logger.Info("备份格式: %s", snapshot.FormatDir)
+			}
+			logger.Info("增量备份: %v", cfg.Incremental)
+			if cfg.Concurrency > 0 {
+				logger.Info("同时复制的文件数量: %d", cfg.Concurrency)
+			} else {
+				logger.Info("同时复制的文件数量: %d (默认)", fileutil.DefaultConcurrency)
+			}
+			logger.Info("保留策略: %s", cfg.RetentionPolicy(0))
+			onGameRunning, _ := process.ParsePolicy(cfg.OnGameRunning)
+			logger.Info("备份时游戏正在运行: %s", onGameRunning)
+			if cfg.Log.File != "" {
+				logger.Info("日志文件: %s (级别: %s, 格式: %s)", cfg.Log.File, orDefault(cfg.Log.Level, "info"), orDefault(cfg.Log.Format, "text"))
+			} else {
+				logger.Info("日志文件: (无)")
+			}
+			if len(cfg.Sources) > 0 {
+				logger.Info("来源:")
+				for _, source := range cfg.Sources {
+					sourceCfg, _ := cfg.ForSource(source.Name)
+					logger.Info("  - %s: %s (备份: %s)", source.Name, sourceCfg.WtfPath, sourceCfg.BackupDir)
+				}
+			}
 			logger.Info("插件列表:")
 			if len(cfg.Addons) == 0 {
 				logger.Info("  (无)")
@@ -239,13 +1064,187 @@ func main() {
 	}
 }
 
+// setProgressFormat 按 -progress-format 设置进度的输出方式，格式无效时退出
+func setProgressFormat(format string) {
+	switch format {
+	case "", "text":
+	case "json":
+		progress.SetDefaultSink(progress.NewJSONSink(os.Stderr))
+	default:
+		logger.Error("无效的进度输出格式 %q (可选: text, json)", format)
+		os.Exit(1)
+	}
+}
+
+// logOptions 所有子命令共用的日志参数
+type logOptions struct {
+	verbose bool
+	quiet   bool
+	level   string
+	format  string
+}
+
+// register 在子命令中注册日志参数
+func (o *logOptions) register(cmd *flag.FlagSet) {
+	cmd.BoolVar(&o.verbose, "v", false, "显示调试日志")
+	cmd.BoolVar(&o.quiet, "q", false, "只显示警告和错误，不显示进度条")
+	cmd.StringVar(&o.level, "log-level", "", "终端中显示的日志级别: debug, info, warn, error (覆盖 -v 和 -q)")
+	cmd.StringVar(&o.format, "log-format", "text", "终端中的日志格式: text, json")
+}
+
+// setupLogging 按日志参数设置终端中的日志，并按配置文件打开日志文件，返回关闭日志文件的函数。
+// 参数无效时退出，日志文件无法打开时只给出警告
+func setupLogging(cfg *config.Config, opts logOptions) func() {
+	level := logger.LogLevelInfo
+	switch {
+	case opts.level != "":
+		var err error
+		if level, err = logger.ParseLevel(opts.level); err != nil {
+			logger.Error("%v", err)
+			os.Exit(1)
+		}
+	case opts.verbose:
+		level = logger.LogLevelDebug
+	case opts.quiet:
+		level = logger.LogLevelWarn
+	}
+	format, err := logger.ParseFormat(opts.format)
+	if err != nil {
+		logger.Error("%v", err)
+		os.Exit(1)
+	}
+
+	log := logger.NewLogger(level, os.Stdout, "WTF-Backup")
+	log.SetFormat(format)
+	logger.SetDefaultLogger(log)
+	if opts.quiet {
+		progress.SetOutput(io.Discard)
+	}
+
+	if cfg.Log.File == "" {
+		return func() {}
+	}
+	maxSize, maxAge, maxBackups := cfg.Log.MaxSizeMB, cfg.Log.MaxAgeDays, cfg.Log.MaxBackups
+	if maxSize == 0 {
+		maxSize = config.DefaultLogMaxSizeMB
+	}
+	if maxAge == 0 {
+		maxAge = config.DefaultLogMaxAgeDays
+	}
+	if maxBackups == 0 {
+		maxBackups = config.DefaultLogMaxBackups
+	}
+	file, err := logger.OpenRotatingFile(cfg.Log.File, int64(maxSize)*1024*1024, time.Duration(maxAge)*24*time.Hour, maxBackups)
+	if err != nil {
+		logger.Warn("无法写入日志文件 %s: %v", cfg.Log.File, err)
+		return func() {}
+	}
+	// 配置文件中的级别和格式已在加载时检查过
+	fileLevel, _ := logger.ParseLevel(cfg.Log.Level)
+	fileFormat, _ := logger.ParseFormat(cfg.Log.Format)
+	log.AddOutput(file, fileLevel, fileFormat)
+	return func() { file.Close() }
+}
+
+// orDefault 空字符串显示为默认值
+func orDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
+
+// orDash 空字符串显示为 -
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// selectSources 按 -source 选择要操作的配置，名称无效时退出
+func selectSources(cfg *config.Config, name string) []config.Config {
+	targets, err := cfg.Select(name)
+	if err != nil {
+		logger.Error("%v", err)
+		os.Exit(1)
+	}
+	return targets
+}
+
+// selectSource 按 -source 选择一个来源的配置，名称无效或选择了多个来源时退出
+func selectSource(cfg config.Config, name string) config.Config {
+	targets := selectSources(&cfg, name)
+	if len(targets) != 1 {
+		logger.Error("该命令只能操作一个来源，请使用 -source 指定 (可选: %s)", strings.Join(cfg.SourceNames(), ", "))
+		os.Exit(1)
+	}
+	return targets[0]
+}
+
+// stringList 可以指定多次的参数，每次指定的值依次追加
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ", ")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// isFlagSet 检查命令行中是否显式指定了某个参数
+func isFlagSet(fs *flag.FlagSet, name string) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// printDryRunReport 输出试运行记录的操作
+func printDryRunReport(dryRun *fileutil.DryRunOperator) {
+	fmt.Println("\n试运行，没有修改任何文件。将要执行的操作:")
+	if err := dryRun.WriteReport(os.Stdout); err != nil {
+		logger.Error("输出试运行结果失败: %v", err)
+	}
+}
+
 func printUsage() {
 	fmt.Println("WTF备份工具 - 备份和恢复魔兽世界的WTF文件夹")
 	fmt.Println("\n用法:")
 	fmt.Println("  backup: 备份WTF文件夹")
-	fmt.Printf("    %s backup [-wtf <WTF文件夹路径>] [-backup <备份文件夹路径>] [-progress] [-keep <保留备份数量>]\n", os.Args[0])
+	fmt.Printf("    %s backup [-source <来源|all>] [-wtf <WTF文件夹路径>] [-backup <备份文件夹路径>] [-format <dir|zip|tar.gz|tar.zst|repo>] [-incremental] [-progress] [-progress-detail] [-progress-format <text|json>] [-keep <保留备份数量>] [-if-running <warn|wait|abort>] [-label <标签>] [-pin] [-note <备注>] [-dry-run]\n", os.Args[0])
 	fmt.Println("  restore: 从备份中恢复插件配置")
-	fmt.Printf("    %s restore [-wtf <WTF文件夹路径>] [-backup <备份文件夹路径>] [-addon <插件名称>] [-progress]\n", os.Args[0])
+	fmt.Printf("    %s restore [-source <来源|all>] [-wtf <WTF文件夹路径>] [-backup <备份文件夹路径>] [-addon <插件名称>] [-from <备份>] [-account <账号>] [-realm <服务器>] [-character <角色>] [-account-wide[=false]] [-characters=false] [-force] [-allow-running] [-progress] [-progress-format <text|json>] [-dry-run]\n", os.Args[0])
+	fmt.Println("  clone: 将一个角色的插件设置复制到另一个角色")
+	fmt.Printf("    %s clone [-source <来源>] -from <账号/服务器/角色> -to <账号/服务器/角色> [-addons <插件1,插件2...>] [-full] [-backup-id <备份|live>] [-force] [-allow-running] [-dry-run]\n", os.Args[0])
+	fmt.Println("  undo: 撤销恢复或克隆操作，还原之前的文件")
+	fmt.Printf("    %s undo [-source <来源>] [-backup <备份文件夹路径>] [-id <快照ID|序号>] [-list] [-allow-running]\n", os.Args[0])
+	fmt.Println("  list: 列出所有备份")
+	fmt.Printf("    %s list [-source <来源|all>] [-backup <备份文件夹路径>]\n", os.Args[0])
+	fmt.Println("  prune: 按保留策略清理旧备份")
+	fmt.Printf("    %s prune [-source <来源|all>] [-keep <保留备份数量>] [-progress-format <text|json>] [-dry-run]\n", os.Args[0])
+	fmt.Println("  pin: 固定备份，清理旧备份时不会删除")
+	fmt.Printf("    %s pin [-source <来源>] [-backup-id <备份>] [-note <备注>]\n", os.Args[0])
+	fmt.Println("  unpin: 取消固定备份")
+	fmt.Printf("    %s unpin [-source <来源>] [-backup-id <备份>]\n", os.Args[0])
+	fmt.Println("  diff: 比较插件配置在两个备份之间的变化")
+	fmt.Printf("    %s diff [-source <来源>] [-addon <插件名称>] [-from <备份|live>] [-to <备份|live>]\n", os.Args[0])
+	fmt.Println("  verify: 按清单校验备份的完整性")
+	fmt.Printf("    %s verify [-source <来源>] [-backup <备份文件夹路径>] [-backup-id <备份名称>]\n", os.Args[0])
+	fmt.Println("  inspect: 列出WTF文件夹或备份中的账号、服务器、角色以及各插件的设置文件")
+	fmt.Printf("    %s inspect [-source <来源>] [-backup-id <备份|live>] [-json]\n", os.Args[0])
+	fmt.Println("  detect: 查找已安装的魔兽世界及各个游戏版本的WTF文件夹")
+	fmt.Printf("    %s detect [-use <retail|classic|classic_era|ptr|beta>]\n", os.Args[0])
 	fmt.Println("  config: 配置设置")
-	fmt.Printf("    %s config [-wtf <WTF文件夹路径>] [-backup <备份文件夹路径>] [-add-addons <插件1,插件2...>] [-remove-addons <插件1,插件2...>] [-show]\n", os.Args[0])
+	fmt.Printf("    %s config [-wtf <WTF文件夹路径>] [-backup <备份文件夹路径>] [-format <备份格式>] [-incremental[=false]] [-add-addons <插件1,插件2...>] [-remove-addons <插件1,插件2...>] [-keep <数量>] [-retention <hours=24,daily=7,weekly=4,monthly=6>] [-on-game-running <warn|wait|abort>] [-concurrency <数量>] [-add-source <名称>=<WTF文件夹路径>]... [-remove-source <名称>] [-log-file <日志文件路径|none>] [-show]\n", os.Args[0])
+	fmt.Println("\n所有命令都支持的日志参数:")
+	fmt.Println("  -v  显示调试日志")
+	fmt.Println("  -q  只显示警告和错误，不显示进度条")
+	fmt.Println("  -log-level <debug|info|warn|error>  终端中显示的日志级别")
+	fmt.Println("  -log-format <text|json>  终端中的日志格式")
 }
@@ -0,0 +1,210 @@
+package detect
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// Flavor 游戏版本对应的文件夹
+type Flavor string
+
+const (
+	// Retail 正式服
+	Retail Flavor = "_retail_"
+	// Classic 怀旧服
+	Classic Flavor = "_classic_"
+	// ClassicEra 经典旧世
+	ClassicEra Flavor = "_classic_era_"
+	// PTR 公共测试服
+	PTR Flavor = "_ptr_"
+	// Beta 测试版
+	Beta Flavor = "_beta_"
+)
+
+// Flavors 所有支持的游戏版本，按自动选择时的优先级排列
+var Flavors = []Flavor{Retail, Classic, ClassicEra, PTR, Beta}
+
+// String 返回游戏版本的中文名称
+func (f Flavor) String() string {
+	switch f {
+	case Retail:
+		return "正式服"
+	case Classic:
+		return "怀旧服"
+	case ClassicEra:
+		return "经典旧世"
+	case PTR:
+		return "测试服"
+	case Beta:
+		return "测试版"
+	default:
+		return string(f)
+	}
+}
+
+// ParseFlavor 解析游戏版本，允许省略两侧的下划线，例如 retail
+func ParseFlavor(s string) (Flavor, bool) {
+	s = "_" + strings.Trim(strings.ToLower(strings.TrimSpace(s)), "_") + "_"
+	for _, f := range Flavors {
+		if string(f) == s {
+			return f, true
+		}
+	}
+	return "", false
+}
+
+// FlavorOf 根据WTF文件夹所在的游戏版本文件夹判断游戏版本，例如 .../_retail_/WTF
+func FlavorOf(wtfPath string) (Flavor, bool) {
+	name := filepath.Base(filepath.Dir(filepath.Clean(wtfPath)))
+	for _, f := range Flavors {
+		if strings.EqualFold(name, string(f)) {
+			return f, true
+		}
+	}
+	return "", false
+}
+
+// Installation 找到的一个游戏版本
+type Installation struct {
+	// 游戏安装目录，例如 C:\Program Files (x86)\World of Warcraft
+	Root   string
+	Flavor Flavor
+	// 该版本的WTF文件夹
+	WtfPath string
+	// WTF文件夹是否已存在（游戏至少运行过一次）
+	HasWTF bool
+}
+
+// Detect 在当前操作系统的常见安装位置中查找魔兽世界
+func Detect() []Installation {
+	return Find(Candidates())
+}
+
+// Find 在给定的安装目录中查找各个游戏版本
+func Find(roots []string) []Installation {
+	var found []Installation
+	for _, root := range roots {
+		for _, flavor := range Flavors {
+			dir := filepath.Join(root, string(flavor))
+			if !isDir(dir) {
+				continue
+			}
+			wtfPath := filepath.Join(dir, "WTF")
+			found = append(found, Installation{
+				Root:    root,
+				Flavor:  flavor,
+				WtfPath: wtfPath,
+				HasWTF:  isDir(wtfPath),
+			})
+		}
+	}
+	return found
+}
+
+// Preferred 返回自动选择时使用的WTF文件夹：按 Flavors 的顺序选择第一个已存在的WTF文件夹
+func Preferred(installs []Installation) (Installation, bool) {
+	for _, flavor := range Flavors {
+		for _, install := range installs {
+			if install.Flavor == flavor && install.HasWTF {
+				return install, true
+			}
+		}
+	}
+	return Installation{}, false
+}
+
+// Candidates 返回当前操作系统上可能的游戏安装目录（只包含存在的目录）
+func Candidates() []string {
+	home, _ := os.UserHomeDir()
+
+	var patterns []string
+	switch runtime.GOOS {
+	case "windows":
+		// 没有读取注册表，而是检查战网默认的安装位置以及各个磁盘上的常见位置
+		for _, env := range []string{"ProgramFiles(x86)", "ProgramFiles", "ProgramW6432"} {
+			if dir := os.Getenv(env); dir != "" {
+				patterns = append(patterns, filepath.Join(dir, "World of Warcraft"))
+			}
+		}
+		for drive := 'C'; drive <= 'Z'; drive++ {
+			root := string(drive) + `:\`
+			if !isDir(root) {
+				continue
+			}
+			patterns = append(patterns,
+				filepath.Join(root, "Program Files (x86)", "World of Warcraft"),
+				filepath.Join(root, "Program Files", "World of Warcraft"),
+				filepath.Join(root, "World of Warcraft"),
+				filepath.Join(root, "Games", "World of Warcraft"),
+				filepath.Join(root, "Battle.net", "World of Warcraft"),
+				filepath.Join(root, "Blizzard", "World of Warcraft"),
+			)
+		}
+
+	case "darwin":
+		patterns = append(patterns, "/Applications/World of Warcraft")
+		if home != "" {
+			patterns = append(patterns, filepath.Join(home, "Applications", "World of Warcraft"))
+		}
+
+	default:
+		// Linux 上通过 Wine、Lutris、Proton 或 Bottles 运行，游戏位于 Wine 前缀的 drive_c 中
+		var prefixes []string
+		if prefix := os.Getenv("WINEPREFIX"); prefix != "" {
+			prefixes = append(prefixes, prefix)
+		}
+		if home != "" {
+			prefixes = append(prefixes,
+				filepath.Join(home, ".wine"),
+				// Lutris
+				filepath.Join(home, "Games", "*"),
+				// Proton (Steam 中添加的非 Steam 游戏)
+				filepath.Join(home, ".steam", "steam", "steamapps", "compatdata", "*", "pfx"),
+				filepath.Join(home, ".local", "share", "Steam", "steamapps", "compatdata", "*", "pfx"),
+				// Bottles
+				filepath.Join(home, ".local", "share", "bottles", "bottles", "*"),
+				filepath.Join(home, ".var", "app", "com.usebottles.bottles", "data", "bottles", "bottles", "*"),
+			)
+		}
+		for _, prefix := range prefixes {
+			patterns = append(patterns,
+				filepath.Join(prefix, "drive_c", "Program Files (x86)", "World of Warcraft"),
+				filepath.Join(prefix, "drive_c", "Program Files", "World of Warcraft"),
+			)
+		}
+	}
+
+	return expand(patterns)
+}
+
+// expand 展开通配符并去除重复和不存在的目录
+func expand(patterns []string) []string {
+	seen := make(map[string]bool)
+	var dirs []string
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			continue
+		}
+		for _, dir := range matches {
+			key := dir
+			if runtime.GOOS == "windows" || runtime.GOOS == "darwin" {
+				key = strings.ToLower(dir)
+			}
+			if seen[key] || !isDir(dir) {
+				continue
+			}
+			seen[key] = true
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+// isDir 检查路径是否为已存在的文件夹
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
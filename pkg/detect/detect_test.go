@@ -0,0 +1,117 @@
+package detect
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseFlavor(t *testing.T) {
+	tests := []struct {
+		input string
+		want  Flavor
+		ok    bool
+	}{
+		{"retail", Retail, true},
+		{"_retail_", Retail, true},
+		{" Classic ", Classic, true},
+		{"classic_era", ClassicEra, true},
+		{"_PTR_", PTR, true},
+		{"beta", Beta, true},
+		{"era", "", false},
+		{"", "", false},
+		{"__", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := ParseFlavor(tt.input)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("ParseFlavor(%q) = %q, %v，期望 %q, %v", tt.input, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestFlavorOf(t *testing.T) {
+	tests := []struct {
+		wtfPath string
+		want    Flavor
+		ok      bool
+	}{
+		{filepath.Join("games", "World of Warcraft", "_retail_", "WTF"), Retail, true},
+		{filepath.Join("games", "World of Warcraft", "_classic_era_", "WTF") + string(filepath.Separator), ClassicEra, true},
+		{filepath.Join("games", "World of Warcraft", "_Classic_", "WTF"), Classic, true},
+		{filepath.Join("games", "backup", "WTF"), "", false},
+		{"WTF", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := FlavorOf(tt.wtfPath)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("FlavorOf(%q) = %q, %v，期望 %q, %v", tt.wtfPath, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+// mkdirs 创建文件夹
+func mkdirs(t *testing.T, dirs ...string) {
+	t.Helper()
+	for _, dir := range dirs {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestFind(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "World of Warcraft")
+	// 正式服还没有运行过，没有WTF文件夹
+	mkdirs(t,
+		filepath.Join(root, "_retail_"),
+		filepath.Join(root, "_classic_", "WTF"),
+		filepath.Join(root, "Data"),
+	)
+	missing := filepath.Join(t.TempDir(), "missing")
+
+	got := Find([]string{root, missing})
+	want := []Installation{
+		{Root: root, Flavor: Retail, WtfPath: filepath.Join(root, "_retail_", "WTF"), HasWTF: false},
+		{Root: root, Flavor: Classic, WtfPath: filepath.Join(root, "_classic_", "WTF"), HasWTF: true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Find 返回 %+v，期望 %+v", got, want)
+	}
+
+	// 正式服没有WTF文件夹时选择怀旧服
+	if install, ok := Preferred(got); !ok || install.Flavor != Classic {
+		t.Errorf("Preferred 选择了 %+v，期望怀旧服", install)
+	}
+	mkdirs(t, filepath.Join(root, "_retail_", "WTF"))
+	if install, ok := Preferred(Find([]string{root})); !ok || install.Flavor != Retail {
+		t.Errorf("Preferred 选择了 %+v，期望正式服", install)
+	}
+	if _, ok := Preferred(nil); ok {
+		t.Errorf("没有安装时 Preferred 应返回 false")
+	}
+}
+
+func TestExpand(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "games", "a", "World of Warcraft")
+	b := filepath.Join(dir, "games", "b", "World of Warcraft")
+	mkdirs(t, a, b)
+	file := filepath.Join(dir, "games", "c")
+	if err := os.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	got := expand([]string{
+		filepath.Join(dir, "games", "*", "World of Warcraft"),
+		a,
+		file,
+		filepath.Join(dir, "missing"),
+		"[",
+	})
+	want := []string{a, b}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expand 返回 %v，期望 %v", got, want)
+	}
+}
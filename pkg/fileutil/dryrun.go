@@ -0,0 +1,265 @@
+package fileutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/lizhening/WtfBackup/pkg/progress"
+)
+
+// OpKind 试运行时记录的操作类型
+type OpKind string
+
+const (
+	// OpCreate 创建新文件
+	OpCreate OpKind = "create"
+	// OpOverwrite 覆盖已存在的文件
+	OpOverwrite OpKind = "overwrite"
+	// OpLink 创建硬链接
+	OpLink OpKind = "link"
+	// OpSkip 跳过的文件
+	OpSkip OpKind = "skip"
+	// OpRemove 删除文件或备份
+	OpRemove OpKind = "remove"
+)
+
+// String 返回操作类型的中文名称
+func (k OpKind) String() string {
+	switch k {
+	case OpCreate:
+		return "创建"
+	case OpOverwrite:
+		return "覆盖"
+	case OpLink:
+		return "链接"
+	case OpSkip:
+		return "跳过"
+	case OpRemove:
+		return "删除"
+	default:
+		return string(k)
+	}
+}
+
+// Op 试运行时记录的一个操作
+type Op struct {
+	Kind OpKind
+	// 被创建、覆盖或删除的路径
+	Path string
+	// 源文件路径，没有时为空
+	Source string
+	// 文件大小
+	Size int64
+	// 覆盖时新旧文件的内容是否不同
+	Differs bool
+	// 说明，例如跳过的原因
+	Reason string
+}
+
+// DryRunOperator 只记录写操作而不修改磁盘的文件操作器
+// 读取操作（Walk、GetFileSize 等）交给被包装的文件操作器完成
+type DryRunOperator struct {
+	FileOperator
+
+	mu  sync.Mutex
+	ops []Op
+}
+
+// NewDryRunOperator 创建试运行文件操作器
+func NewDryRunOperator(base FileOperator) *DryRunOperator {
+	return &DryRunOperator{FileOperator: base}
+}
+
+// IsDryRun 判断文件操作器是否为试运行模式
+func IsDryRun(op FileOperator) bool {
+	_, ok := op.(*DryRunOperator)
+	return ok
+}
+
+// Record 记录一个操作
+func (d *DryRunOperator) Record(op Op) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.ops = append(d.ops, op)
+}
+
+// Ops 返回已记录的所有操作
+func (d *DryRunOperator) Ops() []Op {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]Op(nil), d.ops...)
+}
+
+// Copy 记录复制文件
+func (d *DryRunOperator) Copy(ctx context.Context, src, dst string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	info, err := os.Stat(src)
+	if err != nil {
+		return fmt.Errorf("获取源文件信息失败: %w", err)
+	}
+	return d.recordWrite(dst, src, info.Size(), func() (io.ReadCloser, error) {
+		return os.Open(src)
+	})
+}
+
+// CopyWithProgress 记录复制文件
+func (d *DryRunOperator) CopyWithProgress(ctx context.Context, src, dst string) error {
+	return d.Copy(ctx, src, dst)
+}
+
+// CopyFromFS 记录从文件系统中复制文件
+func (d *DryRunOperator) CopyFromFS(ctx context.Context, fsys fs.FS, name, dst string, showProgress bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	info, err := fs.Stat(fsys, name)
+	if err != nil {
+		return fmt.Errorf("获取源文件信息失败: %w", err)
+	}
+	return d.recordWrite(dst, name, info.Size(), func() (io.ReadCloser, error) {
+		return fsys.Open(name)
+	})
+}
+
+// recordWrite 根据目标文件是否存在记录创建或覆盖，覆盖时比较内容是否不同
+func (d *DryRunOperator) recordWrite(dst, src string, size int64, open func() (io.ReadCloser, error)) error {
+	op := Op{Kind: OpCreate, Path: dst, Source: src, Size: size}
+
+	dstInfo, err := os.Stat(dst)
+	switch {
+	case err == nil:
+		op.Kind = OpOverwrite
+		op.Differs = true
+		if dstInfo.Size() == size {
+			same, err := sameAsFile(open, dst)
+			if err != nil {
+				return err
+			}
+			op.Differs = !same
+		}
+	case !errors.Is(err, fs.ErrNotExist):
+		return fmt.Errorf("获取目标文件信息失败: %w", err)
+	}
+
+	d.Record(op)
+	return nil
+}
+
+// sameAsFile 比较 open 打开的内容与文件 path 是否相同
+func sameAsFile(open func() (io.ReadCloser, error), path string) (bool, error) {
+	r, err := open()
+	if err != nil {
+		return false, fmt.Errorf("打开源文件失败: %w", err)
+	}
+	defer r.Close()
+
+	f, err := os.Open(path)
+	if err != nil {
+		return false, fmt.Errorf("打开目标文件失败: %w", err)
+	}
+	defer f.Close()
+
+	return SameContent(r, f)
+}
+
+// Link 记录创建硬链接
+func (d *DryRunOperator) Link(ctx context.Context, src, dst string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	info, err := os.Stat(src)
+	if err != nil {
+		return fmt.Errorf("获取源文件信息失败: %w", err)
+	}
+	d.Record(Op{Kind: OpLink, Path: dst, Source: src, Size: info.Size()})
+	return nil
+}
+
+// EnsureDir 试运行时不创建目录
+func (d *DryRunOperator) EnsureDir(path string) error {
+	return nil
+}
+
+// CopyDir 记录复制目录中的每个文件
+func (d *DryRunOperator) CopyDir(ctx context.Context, src, dst string, showProgress bool) error {
+	if _, err := os.Stat(src); err != nil {
+		return fmt.Errorf("获取源目录信息失败: %w", err)
+	}
+
+	err := d.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(src, path)
+		if err != nil {
+			return fmt.Errorf("计算相对路径失败: %w", err)
+		}
+		return d.Copy(ctx, path, filepath.Join(dst, relPath))
+	})
+	if err != nil {
+		return fmt.Errorf("遍历目录失败: %w", err)
+	}
+	return nil
+}
+
+// Remove 记录删除文件或文件夹，路径不存在时不记录
+func (d *DryRunOperator) Remove(path string) error {
+	if _, err := os.Lstat(path); errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	size, err := d.GetDirSize(path)
+	if err != nil {
+		return fmt.Errorf("获取 %s 的大小失败: %w", path, err)
+	}
+	d.Record(Op{Kind: OpRemove, Path: path, Size: size})
+	return nil
+}
+
+// WriteReport 输出记录的所有操作及汇总
+func (d *DryRunOperator) WriteReport(w io.Writer) error {
+	ops := d.Ops()
+	if len(ops) == 0 {
+		_, err := fmt.Fprintln(w, "没有需要执行的操作")
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	counts := make(map[OpKind]int)
+	var written int64
+	for _, op := range ops {
+		counts[op.Kind]++
+		if op.Kind == OpCreate || (op.Kind == OpOverwrite && op.Differs) {
+			written += op.Size
+		}
+
+		note := op.Reason
+		if op.Kind == OpOverwrite {
+			note = "内容相同"
+			if op.Differs {
+				note = "内容不同"
+			}
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", op.Kind, progress.FormatBytes(op.Size), op.Path, note)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	_, err := fmt.Fprintf(w, "\n共 %d 个操作: 创建 %d, 覆盖 %d, 链接 %d, 跳过 %d, 删除 %d, 将写入 %s\n",
+		len(ops), counts[OpCreate], counts[OpOverwrite], counts[OpLink], counts[OpSkip], counts[OpRemove],
+		progress.FormatBytes(written))
+	return err
+}
@@ -0,0 +1,123 @@
+package fileutil
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeFile 写入测试文件，自动创建上级目录
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDryRunRecordWrite(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src.lua")
+	writeFile(t, src, "abc")
+
+	tests := []struct {
+		name        string
+		existing    string // 目标文件原有的内容，为空时目标文件不存在
+		wantKind    OpKind
+		wantDiffers bool
+	}{
+		{"创建", "", OpCreate, false},
+		{"覆盖相同的内容", "abc", OpOverwrite, false},
+		{"覆盖大小相同内容不同", "abd", OpOverwrite, true},
+		{"覆盖大小不同", "abcdef", OpOverwrite, true},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dst := filepath.Join(dir, "dst", tt.name+".lua")
+			if tt.existing != "" {
+				writeFile(t, dst, tt.existing)
+			}
+
+			d := NewDryRunOperator(NewDefaultFileOperator(0, 0))
+			if err := d.Copy(context.Background(), src, dst); err != nil {
+				t.Fatalf("Copy 失败: %v", err)
+			}
+			ops := d.Ops()
+			if len(ops) != 1 {
+				t.Fatalf("记录了 %d 个操作，期望 1 个", len(ops))
+			}
+			op := ops[0]
+			if op.Kind != tt.wantKind || op.Differs != tt.wantDiffers || op.Size != 3 || op.Path != dst || op.Source != src {
+				t.Errorf("第 %d 个用例记录了 %+v", i, op)
+			}
+
+			// 试运行不能修改目标文件
+			data, err := os.ReadFile(dst)
+			if tt.existing == "" {
+				if !os.IsNotExist(err) {
+					t.Errorf("试运行创建了目标文件")
+				}
+			} else if string(data) != tt.existing {
+				t.Errorf("试运行修改了目标文件: %q", data)
+			}
+		})
+	}
+}
+
+func TestDryRunCopyDirDoesNotWrite(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "WTF")
+	writeFile(t, filepath.Join(src, "Config.wtf"), "SET a 1")
+	writeFile(t, filepath.Join(src, "Account", "A", "SavedVariables", "X.lua"), "X = 1")
+	dst := filepath.Join(t.TempDir(), "out")
+
+	d := NewDryRunOperator(NewDefaultFileOperator(0, 0))
+	if err := d.CopyDir(context.Background(), src, dst, false); err != nil {
+		t.Fatalf("CopyDir 失败: %v", err)
+	}
+	if n := len(d.Ops()); n != 2 {
+		t.Errorf("记录了 %d 个操作，期望 2 个", n)
+	}
+	if _, err := os.Stat(dst); !os.IsNotExist(err) {
+		t.Errorf("试运行创建了目标目录")
+	}
+}
+
+func TestWriteReport(t *testing.T) {
+	d := NewDryRunOperator(nil)
+	var buf bytes.Buffer
+	if err := d.WriteReport(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "没有需要执行的操作") {
+		t.Errorf("没有操作时输出 %q", buf.String())
+	}
+
+	d.Record(Op{Kind: OpCreate, Path: "a", Size: 100})
+	d.Record(Op{Kind: OpCreate, Path: "b", Size: 200})
+	d.Record(Op{Kind: OpOverwrite, Path: "c", Size: 300, Differs: true})
+	// 内容相同的覆盖、链接、跳过和删除都不计入写入的大小
+	d.Record(Op{Kind: OpOverwrite, Path: "d", Size: 5000})
+	d.Record(Op{Kind: OpLink, Path: "e", Size: 7000})
+	d.Record(Op{Kind: OpSkip, Path: "f", Size: 9000, Reason: "未变化"})
+	d.Record(Op{Kind: OpRemove, Path: "g", Size: 11000})
+
+	buf.Reset()
+	if err := d.WriteReport(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	want := "共 7 个操作: 创建 2, 覆盖 2, 链接 1, 跳过 1, 删除 1, 将写入 600 B"
+	if !strings.Contains(out, want) {
+		t.Errorf("汇总不正确:\n%s\n期望包含: %s", out, want)
+	}
+	for _, note := range []string{"内容不同", "内容相同", "未变化"} {
+		if !strings.Contains(out, note) {
+			t.Errorf("报告中缺少 %q:\n%s", note, out)
+		}
+	}
+}
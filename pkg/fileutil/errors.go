@@ -0,0 +1,43 @@
+package fileutil
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FileError 一个文件操作失败
+type FileError struct {
+	// 相对于源目录的路径
+	Path string
+	Err  error
+}
+
+func (e FileError) Error() string {
+	return e.Path + ": " + e.Err.Error()
+}
+
+func (e FileError) Unwrap() error {
+	return e.Err
+}
+
+// CopyError 复制目录时失败的所有文件
+type CopyError struct {
+	Files []FileError
+}
+
+func (e *CopyError) Error() string {
+	lines := make([]string, len(e.Files))
+	for i, f := range e.Files {
+		lines[i] = f.Error()
+	}
+	return fmt.Sprintf("%d 个文件复制失败:\n  %s", len(e.Files), strings.Join(lines, "\n  "))
+}
+
+// Unwrap 支持 errors.Is 和 errors.As 检查其中任意一个文件的错误
+func (e *CopyError) Unwrap() []error {
+	errs := make([]error, len(e.Files))
+	for i, f := range e.Files {
+		errs[i] = f
+	}
+	return errs
+}
@@ -1,10 +1,14 @@
 package fileutil
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"sort"
 	"sync"
 
 	"github.com/lizhening/WtfBackup/pkg/logger"
@@ -13,33 +17,65 @@ import (
 
 // FileOperator 文件操作接口
 type FileOperator interface {
-	Copy(src, dst string) error
-	CopyWithProgress(src, dst string) error
+	Copy(ctx context.Context, src, dst string) error
+	CopyWithProgress(ctx context.Context, src, dst string) error
+	CopyFromFS(ctx context.Context, fsys fs.FS, name, dst string, showProgress bool) error
+	Link(ctx context.Context, src, dst string) error
 	Walk(root string, walkFn filepath.WalkFunc) error
 	EnsureDir(path string) error
 	GetFileSize(path string) (int64, error)
-	CopyDir(src, dst string, showProgress bool) error
+	CopyDir(ctx context.Context, src, dst string, showProgress bool) error
 	GetDirSize(path string) (int64, error)
-	CleanOldBackups(backupDir string, keepCount int) error
+	Remove(path string) error
 }
 
+// DefaultConcurrency 复制文件夹时默认同时复制的文件数量
+const DefaultConcurrency = 8
+
 // DefaultFileOperator 默认文件操作实现
 type DefaultFileOperator struct {
-	bufferSize int64
+	bufferSize  int64
+	concurrency int
 }
 
-// NewDefaultFileOperator 创建默认文件操作器
-func NewDefaultFileOperator(bufferSize int64) *DefaultFileOperator {
+// NewDefaultFileOperator 创建默认文件操作器，concurrency 为复制文件夹时同时复制的文件数量
+func NewDefaultFileOperator(bufferSize int64, concurrency int) *DefaultFileOperator {
 	if bufferSize <= 0 {
 		bufferSize = 32 * 1024 // 默认32KB
 	}
+	if concurrency <= 0 {
+		concurrency = DefaultConcurrency
+	}
 	return &DefaultFileOperator{
-		bufferSize: bufferSize,
+		bufferSize:  bufferSize,
+		concurrency: concurrency,
 	}
 }
 
-// Copy 复制文件
-func (op *DefaultFileOperator) Copy(src, dst string) error {
+// Copy 复制文件，ctx 已被取消时不会开始复制
+func (op *DefaultFileOperator) Copy(ctx context.Context, src, dst string) error {
+	return op.copyFile(ctx, src, dst, nil)
+}
+
+// CopyWithProgress 带进度显示的复制文件
+func (op *DefaultFileOperator) CopyWithProgress(ctx context.Context, src, dst string) error {
+	var pw *progress.ProgressWriter
+	err := op.copyFile(ctx, src, dst, func(w io.Writer, srcInfo os.FileInfo) io.Writer {
+		pw = progress.NewProgressWriter(w, srcInfo.Size(), "复制文件", filepath.Base(src))
+		return pw
+	})
+	if pw != nil {
+		pw.Finish()
+	}
+	return err
+}
+
+// copyFile 复制文件，wrap 不为 nil 时用于包装写入目标文件的写入器（例如统计进度）
+func (op *DefaultFileOperator) copyFile(ctx context.Context, src, dst string, wrap func(io.Writer, os.FileInfo) io.Writer) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	srcFile, err := os.Open(src)
 	if err != nil {
 		return fmt.Errorf("打开源文件失败: %w", err)
@@ -62,18 +98,26 @@ func (op *DefaultFileOperator) Copy(src, dst string) error {
 	}
 	defer dstFile.Close()
 
+	var w io.Writer = dstFile
+	if wrap != nil {
+		w = wrap(dstFile, srcInfo)
+	}
 	buffer := make([]byte, op.bufferSize)
-	_, err = io.CopyBuffer(dstFile, srcFile, buffer)
+	_, err = io.CopyBuffer(w, srcFile, buffer)
 	if err != nil {
 		return fmt.Errorf("复制文件内容失败: %w", err)
 	}
 
-	return nil
+	return closeAndPreserveModTime(dstFile, srcInfo)
 }
 
-// CopyWithProgress 带进度显示的复制文件
-func (op *DefaultFileOperator) CopyWithProgress(src, dst string) error {
-	srcFile, err := os.Open(src)
+// CopyFromFS 从文件系统（例如压缩包备份）中复制单个文件到 dst
+func (op *DefaultFileOperator) CopyFromFS(ctx context.Context, fsys fs.FS, name, dst string, showProgress bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	srcFile, err := fsys.Open(name)
 	if err != nil {
 		return fmt.Errorf("打开源文件失败: %w", err)
 	}
@@ -89,21 +133,55 @@ func (op *DefaultFileOperator) CopyWithProgress(src, dst string) error {
 		return err
 	}
 
-	dstFile, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, srcInfo.Mode())
+	dstFile, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, srcInfo.Mode().Perm())
 	if err != nil {
 		return fmt.Errorf("创建目标文件失败: %w", err)
 	}
 	defer dstFile.Close()
 
-	// 创建进度写入器
-	progressWriter := progress.NewProgressWriter(dstFile, srcInfo.Size(), "复制文件", filepath.Base(src))
+	var w io.Writer = dstFile
+	if showProgress {
+		pw := progress.NewProgressWriter(dstFile, srcInfo.Size(), "复制文件", srcInfo.Name())
+		defer pw.Finish()
+		w = pw
+	}
 	buffer := make([]byte, op.bufferSize)
-	_, err = io.CopyBuffer(progressWriter, srcFile, buffer)
+	_, err = io.CopyBuffer(w, srcFile, buffer)
 	if err != nil {
 		return fmt.Errorf("复制文件内容失败: %w", err)
 	}
 
-	fmt.Println() // 换行
+	return closeAndPreserveModTime(dstFile, srcInfo)
+}
+
+// Link 为 src 创建硬链接 dst，无法创建硬链接时（例如跨磁盘）退回到复制
+func (op *DefaultFileOperator) Link(ctx context.Context, src, dst string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// 确保目标目录存在
+	if err := op.EnsureDir(filepath.Dir(dst)); err != nil {
+		return err
+	}
+
+	if err := os.Link(src, dst); err != nil {
+		logger.Debug("创建硬链接失败，改为复制 %s: %v", src, err)
+		return op.Copy(ctx, src, dst)
+	}
+	return nil
+}
+
+// closeAndPreserveModTime 关闭目标文件并保留源文件的修改时间
+func closeAndPreserveModTime(dstFile *os.File, srcInfo fs.FileInfo) error {
+	if err := dstFile.Close(); err != nil {
+		return fmt.Errorf("写入目标文件失败: %w", err)
+	}
+	if modTime := srcInfo.ModTime(); !modTime.IsZero() {
+		if err := os.Chtimes(dstFile.Name(), modTime, modTime); err != nil {
+			return fmt.Errorf("设置文件修改时间失败: %w", err)
+		}
+	}
 	return nil
 }
 
@@ -129,13 +207,13 @@ func (op *DefaultFileOperator) GetFileSize(path string) (int64, error) {
 	return info.Size(), nil
 }
 
-// CopyDir 复制目录
-func (op *DefaultFileOperator) CopyDir(src, dst string, showProgress bool) error {
-	var wg sync.WaitGroup
-	errChan := make(chan error, 1)
-
+// CopyDir 复制目录，最多同时复制 concurrency 个文件
+// ctx 被取消时不再开始复制新的文件（已开始复制的文件会写完），返回的错误包含 ctx.Err()。
+// 个别文件复制失败时继续复制其他文件，最后返回包含所有失败文件的 *CopyError。
+// showProgress 为 true 时显示整个目录的复制进度
+func (op *DefaultFileOperator) CopyDir(ctx context.Context, src, dst string, showProgress bool) (err error) {
 	// 获取源目录信息
-	_, err := os.Stat(src)
+	_, err = os.Stat(src)
 	if err != nil {
 		return fmt.Errorf("获取源目录信息失败: %w", err)
 	}
@@ -145,16 +223,81 @@ func (op *DefaultFileOperator) CopyDir(src, dst string, showProgress bool) error
 		return err
 	}
 
-	// 遍历源目录
-	err = op.Walk(src, func(path string, info os.FileInfo, err error) error {
+	// 多个文件同时复制，只显示一个整体的进度条
+	var bar *progress.ProgressBar
+	var wrap func(io.Writer, os.FileInfo) io.Writer
+	if showProgress {
+		bar, err = NewDirProgress(op, src, "复制文件", filepath.Base(dst))
 		if err != nil {
 			return err
 		}
+		wrap = func(w io.Writer, _ os.FileInfo) io.Writer {
+			return bar.Writer(w)
+		}
+		defer func() {
+			if err != nil {
+				bar.Stop()
+			} else {
+				bar.Finish()
+			}
+		}()
+	}
+
+	type job struct {
+		src, dst, relPath string
+	}
+	jobs := make(chan job)
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		failed []FileError
+	)
+	fail := func(relPath string, err error) {
+		mu.Lock()
+		failed = append(failed, FileError{Path: relPath, Err: err})
+		mu.Unlock()
+	}
+
+	// 固定数量的复制协程，避免同时打开过多文件
+	for i := 0; i < op.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				err := op.copyFile(ctx, j.src, j.dst, wrap)
+				// 取消后未开始的文件不算复制失败
+				if err != nil && ctx.Err() == nil {
+					fail(j.relPath, err)
+				}
+				if err == nil && bar != nil {
+					bar.FileDone(filepath.ToSlash(j.relPath))
+				}
+			}
+		}()
+	}
+
+	// 遍历源目录
+	err = op.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
 
 		// 计算相对路径
-		relPath, err := filepath.Rel(src, path)
+		relPath, relErr := filepath.Rel(src, path)
+		if relErr != nil {
+			return fmt.Errorf("计算相对路径失败: %w", relErr)
+		}
+
+		// 无法读取的文件或子目录记录下来后跳过，继续复制其他文件
 		if err != nil {
-			return fmt.Errorf("计算相对路径失败: %w", err)
+			if path == src {
+				return err
+			}
+			fail(relPath, err)
+			if info != nil && info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
 		}
 
 		// 构建目标路径
@@ -163,44 +306,58 @@ func (op *DefaultFileOperator) CopyDir(src, dst string, showProgress bool) error
 		if info.IsDir() {
 			// 创建目录
 			if err := op.EnsureDir(dstPath); err != nil {
-				return err
+				fail(relPath, err)
+				return filepath.SkipDir
 			}
-		} else {
-			// 复制文件
-			wg.Add(1)
-			go func() {
-				defer wg.Done()
-				var err error
-				if showProgress {
-					err = op.CopyWithProgress(path, dstPath)
-				} else {
-					err = op.Copy(path, dstPath)
-				}
-				if err != nil {
-					select {
-					case errChan <- err:
-					default:
-					}
-				}
-			}()
+			return nil
+		}
+
+		// 复制文件
+		select {
+		case jobs <- job{src: path, dst: dstPath, relPath: relPath}:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
 		}
-		return nil
 	})
 
+	// 等待已开始的文件复制完成
+	close(jobs)
+	wg.Wait()
+
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return fmt.Errorf("复制已取消: %w", ctxErr)
+	}
 	if err != nil {
 		return fmt.Errorf("遍历目录失败: %w", err)
 	}
+	if len(failed) > 0 {
+		sort.Slice(failed, func(i, j int) bool { return failed[i].Path < failed[j].Path })
+		return &CopyError{Files: failed}
+	}
+	return nil
+}
 
-	// 等待所有文件复制完成
-	wg.Wait()
-	close(errChan)
-
-	// 检查是否有错误
-	if err := <-errChan; err != nil {
-		return err
+// NewDirProgress 为复制整个目录创建进度条，预先统计目录中的文件数量和总大小
+func NewDirProgress(op FileOperator, root, prefix, suffix string) (*progress.ProgressBar, error) {
+	size, err := op.GetDirSize(root)
+	if err != nil {
+		return nil, fmt.Errorf("计算文件夹大小失败: %w", err)
+	}
+	files := 0
+	err = op.Walk(root, func(_ string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			files++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("统计文件数量失败: %w", err)
 	}
 
-	return nil
+	bar := progress.NewProgressBar(size, prefix, suffix)
+	bar.SetFiles(files)
+	return bar, nil
 }
 
 // GetDirSize 获取目录大小
@@ -218,42 +375,29 @@ func (op *DefaultFileOperator) GetDirSize(path string) (int64, error) {
 	return size, err
 }
 
-// CleanOldBackups 清理旧备份
-func (op *DefaultFileOperator) CleanOldBackups(backupDir string, keepCount int) error {
-	entries, err := os.ReadDir(backupDir)
-	if err != nil {
-		return fmt.Errorf("读取备份目录失败: %w", err)
-	}
+// Remove 删除文件或文件夹，路径不存在时不返回错误
+func (op *DefaultFileOperator) Remove(path string) error {
+	return os.RemoveAll(path)
+}
 
-	var backups []string
-	for _, entry := range entries {
-		if entry.IsDir() && entry.Name()[:4] == "WTF_" {
-			backups = append(backups, filepath.Join(backupDir, entry.Name()))
+// SameContent 逐块比较两个读取器的内容是否相同
+func SameContent(a, b io.Reader) (bool, error) {
+	bufA := make([]byte, 32*1024)
+	bufB := make([]byte, 32*1024)
+	for {
+		na, errA := io.ReadFull(a, bufA)
+		nb, errB := io.ReadFull(b, bufB)
+		if na != nb || !bytes.Equal(bufA[:na], bufB[:nb]) {
+			return false, nil
 		}
-	}
-
-	if len(backups) <= keepCount {
-		return nil
-	}
-
-	// 按修改时间排序
-	for i := 0; i < len(backups)-1; i++ {
-		for j := i + 1; j < len(backups); j++ {
-			infoI, _ := os.Stat(backups[i])
-			infoJ, _ := os.Stat(backups[j])
-			if infoI.ModTime().Before(infoJ.ModTime()) {
-				backups[i], backups[j] = backups[j], backups[i]
-			}
+		if errA == io.EOF || errA == io.ErrUnexpectedEOF {
+			return errB == io.EOF || errB == io.ErrUnexpectedEOF, nil
 		}
-	}
-
-	// 删除旧备份
-	for _, backup := range backups[keepCount:] {
-		logger.Info("删除旧备份: %s", backup)
-		if err := os.RemoveAll(backup); err != nil {
-			logger.Error("删除备份失败 %s: %v", backup, err)
+		if errA != nil {
+			return false, errA
+		}
+		if errB != nil {
+			return false, errB
 		}
 	}
-
-	return nil
 }
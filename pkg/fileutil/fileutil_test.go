@@ -0,0 +1,81 @@
+package fileutil
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCopyDir(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "WTF")
+	writeFile(t, filepath.Join(src, "Config.wtf"), "SET a 1")
+	writeFile(t, filepath.Join(src, "Account", "A", "SavedVariables", "X.lua"), "X = 1")
+	modTime := time.Date(2024, 5, 1, 20, 0, 0, 0, time.Local)
+	if err := os.Chtimes(filepath.Join(src, "Config.wtf"), modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+	dst := filepath.Join(t.TempDir(), "out")
+
+	if err := NewDefaultFileOperator(0, 2).CopyDir(context.Background(), src, dst, false); err != nil {
+		t.Fatalf("CopyDir 失败: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dst, "Account", "A", "SavedVariables", "X.lua"))
+	if err != nil || string(data) != "X = 1" {
+		t.Errorf("复制的文件内容为 %q, %v", data, err)
+	}
+	info, err := os.Stat(filepath.Join(dst, "Config.wtf"))
+	if err != nil || !info.ModTime().Equal(modTime) {
+		t.Errorf("没有保留修改时间: %v, %v", info, err)
+	}
+}
+
+func TestCopyDirCollectsAllFailures(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "WTF")
+	writeFile(t, filepath.Join(src, "a.lua"), "a")
+	writeFile(t, filepath.Join(src, "b", "ok.lua"), "ok")
+	writeFile(t, filepath.Join(src, "c.lua"), "c")
+	dst := filepath.Join(t.TempDir(), "out")
+	// 目标位置已有同名的文件夹，无法写入这两个文件
+	for _, name := range []string{"c.lua", "a.lua"} {
+		if err := os.MkdirAll(filepath.Join(dst, name), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := NewDefaultFileOperator(0, 2).CopyDir(context.Background(), src, dst, false)
+	var copyErr *CopyError
+	if !errors.As(err, &copyErr) {
+		t.Fatalf("CopyDir 返回 %v，期望 *CopyError", err)
+	}
+	if len(copyErr.Files) != 2 || copyErr.Files[0].Path != "a.lua" || copyErr.Files[1].Path != "c.lua" {
+		t.Errorf("失败的文件为 %v，期望按路径排列的 a.lua 和 c.lua", copyErr.Files)
+	}
+	for _, f := range copyErr.Files {
+		if f.Err == nil {
+			t.Errorf("%s 缺少错误原因", f.Path)
+		}
+	}
+	// 其他文件照常复制
+	if data, err := os.ReadFile(filepath.Join(dst, "b", "ok.lua")); err != nil || string(data) != "ok" {
+		t.Errorf("没有复制其他文件: %q, %v", data, err)
+	}
+}
+
+func TestCopyDirCancelled(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "WTF")
+	writeFile(t, filepath.Join(src, "a.lua"), "a")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := NewDefaultFileOperator(0, 0).CopyDir(ctx, src, filepath.Join(t.TempDir(), "out"), false)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("CopyDir 返回 %v，期望 context.Canceled", err)
+	}
+	var copyErr *CopyError
+	if errors.As(err, &copyErr) {
+		t.Errorf("取消后不应返回 *CopyError")
+	}
+}
@@ -1,9 +1,13 @@
 package logger
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -17,11 +21,91 @@ const (
 	LogLevelError
 )
 
+// String 返回日志级别的名称
+func (l LogLevel) String() string {
+	switch l {
+	case LogLevelDebug:
+		return "DEBUG"
+	case LogLevelInfo:
+		return "INFO"
+	case LogLevelWarn:
+		return "WARN"
+	case LogLevelError:
+		return "ERROR"
+	default:
+		return fmt.Sprintf("LEVEL(%d)", int(l))
+	}
+}
+
+// ParseLevel 解析日志级别: debug, info, warn, error，空字符串视为 info
+func ParseLevel(s string) (LogLevel, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "debug":
+		return LogLevelDebug, nil
+	case "", "info":
+		return LogLevelInfo, nil
+	case "warn", "warning":
+		return LogLevelWarn, nil
+	case "error":
+		return LogLevelError, nil
+	default:
+		return LogLevelInfo, fmt.Errorf("无效的日志级别 %q (可选: debug, info, warn, error)", s)
+	}
+}
+
+// Format 日志的输出格式
+type Format string
+
+const (
+	// FormatText 每条日志一行文本
+	FormatText Format = "text"
+	// FormatJSON 每条日志一行 JSON，附加字段作为 JSON 的键
+	FormatJSON Format = "json"
+)
+
+// ParseFormat 解析日志格式，空字符串视为 text
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(strings.TrimSpace(s))); f {
+	case "":
+		return FormatText, nil
+	case FormatText, FormatJSON:
+		return f, nil
+	default:
+		return FormatText, fmt.Errorf("无效的日志格式 %q (可选: text, json)", s)
+	}
+}
+
+// Fields 日志的附加字段
+type Fields map[string]interface{}
+
+// 常用的附加字段名称
+const (
+	// FieldOp 操作名称，例如 backup、restore、prune
+	FieldOp = "op"
+	// FieldBackup 备份名称
+	FieldBackup = "backup"
+	// FieldAddon 插件名称
+	FieldAddon = "addon"
+	// FieldPath 文件路径
+	FieldPath = "path"
+	// FieldBytes 字节数
+	FieldBytes = "bytes"
+)
+
+// target 一个日志输出目标，每个输出目标有各自的级别和格式
+type target struct {
+	mu     sync.Mutex
+	w      io.Writer
+	level  LogLevel
+	format Format
+}
+
 // Logger 日志记录器结构
 type Logger struct {
-	level  LogLevel
-	output io.Writer
-	prefix string
+	// 第一个为主输出目标，SetLevel、SetOutput 和 SetFormat 作用于主输出目标
+	outputs []*target
+	prefix  string
+	fields  Fields
 }
 
 // NewLogger 创建新的日志记录器
@@ -30,55 +114,129 @@ func NewLogger(level LogLevel, output io.Writer, prefix string) *Logger {
 		output = os.Stdout
 	}
 	return &Logger{
-		level:  level,
-		output: output,
-		prefix: prefix,
+		outputs: []*target{newTarget(output, level, FormatText)},
+		prefix:  prefix,
 	}
 }
 
-// formatMessage 格式化日志消息
-func (l *Logger) formatMessage(level string, format string, args ...interface{}) string {
-	timestamp := time.Now().Format("2006-01-02 15:04:05")
+// newTarget 创建输出目标
+func newTarget(w io.Writer, level LogLevel, format Format) *target {
+	return &target{w: w, level: level, format: format}
+}
+
+// AddOutput 增加一个输出目标，例如日志文件
+func (l *Logger) AddOutput(w io.Writer, level LogLevel, format Format) {
+	l.outputs = append(l.outputs, newTarget(w, level, format))
+}
+
+// With 返回附加了字段的日志记录器，与 l 共用输出目标
+func (l *Logger) With(fields Fields) *Logger {
+	merged := make(Fields, len(l.fields)+len(fields))
+	for k, v := range l.fields {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+	return &Logger{outputs: l.outputs, prefix: l.prefix, fields: merged}
+}
+
+// log 将一条日志写入级别符合的所有输出目标
+func (l *Logger) log(level LogLevel, format string, args ...interface{}) {
+	now := time.Now()
 	message := fmt.Sprintf(format, args...)
-	return fmt.Sprintf("%s [%s] %s: %s\n", timestamp, level, l.prefix, message)
+	for _, out := range l.outputs {
+		if level < out.level {
+			continue
+		}
+		var line string
+		if out.format == FormatJSON {
+			line = l.formatJSON(now, level, message)
+		} else {
+			line = l.formatMessage(now, level, message)
+		}
+		out.mu.Lock()
+		io.WriteString(out.w, line)
+		out.mu.Unlock()
+	}
+}
+
+// formatMessage 格式化日志消息，附加字段按名称排序后以 key=value 的形式写在消息后面
+func (l *Logger) formatMessage(now time.Time, level LogLevel, message string) string {
+	timestamp := now.Format("2006-01-02 15:04:05")
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s [%s] %s: %s", timestamp, level, l.prefix, message)
+	keys := make([]string, 0, len(l.fields))
+	for k := range l.fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		value := fmt.Sprint(l.fields[k])
+		if strings.ContainsAny(value, " \t\"=") {
+			value = fmt.Sprintf("%q", value)
+		}
+		fmt.Fprintf(&sb, " %s=%s", k, value)
+	}
+	sb.WriteByte('\n')
+	return sb.String()
+}
+
+// formatJSON 将日志格式化为一行 JSON
+func (l *Logger) formatJSON(now time.Time, level LogLevel, message string) string {
+	entry := make(map[string]interface{}, len(l.fields)+4)
+	for k, v := range l.fields {
+		entry[k] = v
+	}
+	entry["time"] = now.Format(time.RFC3339Nano)
+	entry["level"] = strings.ToLower(level.String())
+	entry["msg"] = message
+	if l.prefix != "" {
+		entry["logger"] = l.prefix
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return l.formatMessage(now, level, message)
+	}
+	return string(data) + "\n"
 }
 
 // Debug 记录调试级别日志
 func (l *Logger) Debug(format string, args ...interface{}) {
-	if l.level <= LogLevelDebug {
-		fmt.Fprint(l.output, l.formatMessage("DEBUG", format, args...))
-	}
+	l.log(LogLevelDebug, format, args...)
 }
 
 // Info 记录信息级别日志
 func (l *Logger) Info(format string, args ...interface{}) {
-	if l.level <= LogLevelInfo {
-		fmt.Fprint(l.output, l.formatMessage("INFO", format, args...))
-	}
+	l.log(LogLevelInfo, format, args...)
 }
 
 // Warn 记录警告级别日志
 func (l *Logger) Warn(format string, args ...interface{}) {
-	if l.level <= LogLevelWarn {
-		fmt.Fprint(l.output, l.formatMessage("WARN", format, args...))
-	}
+	l.log(LogLevelWarn, format, args...)
 }
 
 // Error 记录错误级别日志
 func (l *Logger) Error(format string, args ...interface{}) {
-	if l.level <= LogLevelError {
-		fmt.Fprint(l.output, l.formatMessage("ERROR", format, args...))
-	}
+	l.log(LogLevelError, format, args...)
 }
 
 // SetLevel 设置日志级别
 func (l *Logger) SetLevel(level LogLevel) {
-	l.level = level
+	l.outputs[0].level = level
 }
 
 // SetOutput 设置输出目标
-func (l *Logger) SetOutput(output io.Writer) {
-	l.output = output
+func (l *Logger) SetOutput(w io.Writer) {
+	out := l.outputs[0]
+	out.mu.Lock()
+	defer out.mu.Unlock()
+	out.w = w
+}
+
+// SetFormat 设置输出格式
+func (l *Logger) SetFormat(format Format) {
+	l.outputs[0].format = format
 }
 
 // SetPrefix 设置日志前缀
@@ -94,6 +252,11 @@ func SetDefaultLogger(logger *Logger) {
 	defaultLogger = logger
 }
 
+// With 返回附加了字段的默认日志记录器
+func With(fields Fields) *Logger {
+	return defaultLogger.With(fields)
+}
+
 // Debug 使用默认日志记录器记录调试级别日志
 func Debug(format string, args ...interface{}) {
 	defaultLogger.Debug(format, args...)
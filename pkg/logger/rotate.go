@@ -0,0 +1,185 @@
+package logger
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rotateTimeFormat 轮换后的日志文件名称中的时间格式
+const rotateTimeFormat = "2006-01-02T15-04-05"
+
+// RotatingFile 按大小和时间轮换的日志文件
+//
+// 文件超过 MaxSize 时重命名为 <名称>-<时间><扩展名> 并重新创建；
+// 打开时如果现有的日志文件超过 MaxAge 没有写入，也会先轮换。
+// 轮换后的文件超过 MaxAge 或超过 MaxBackups 个时会被删除
+type RotatingFile struct {
+	path       string
+	maxSize    int64
+	maxAge     time.Duration
+	maxBackups int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// OpenRotatingFile 打开（或创建）日志文件，maxSize、maxAge 和 maxBackups 为 0 时不按对应的条件轮换或清理
+func OpenRotatingFile(path string, maxSize int64, maxAge time.Duration, maxBackups int) (*RotatingFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("创建日志文件夹失败: %w", err)
+	}
+
+	r := &RotatingFile{path: path, maxSize: maxSize, maxAge: maxAge, maxBackups: maxBackups}
+	if info, err := os.Stat(path); err == nil && info.Size() > 0 && maxAge > 0 && time.Since(info.ModTime()) > maxAge {
+		if err := r.rotate(); err != nil {
+			return nil, err
+		}
+		return r, nil
+	}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// open 以追加方式打开日志文件
+func (r *RotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("打开日志文件失败: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("获取日志文件信息失败: %w", err)
+	}
+	r.file = f
+	r.size = info.Size()
+	return nil
+}
+
+// Write 实现 io.Writer 接口，写入后超过大小限制时先轮换
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.maxSize > 0 && r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// Close 关闭日志文件
+func (r *RotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+// rotate 将当前的日志文件重命名后重新创建，并清理过期的日志文件
+func (r *RotatingFile) rotate() error {
+	if r.file != nil {
+		r.file.Close()
+		r.file = nil
+	}
+
+	base, ext := r.splitName()
+	now := time.Now()
+	rotated := filepath.Join(filepath.Dir(r.path), base+"-"+now.Format(rotateTimeFormat)+ext)
+	// 同一秒内多次轮换时加上序号
+	for i := 1; ; i++ {
+		if _, err := os.Stat(rotated); os.IsNotExist(err) {
+			break
+		}
+		rotated = filepath.Join(filepath.Dir(r.path), fmt.Sprintf("%s-%s.%d%s", base, now.Format(rotateTimeFormat), i, ext))
+	}
+	if err := os.Rename(r.path, rotated); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("轮换日志文件失败: %w", err)
+	}
+
+	r.removeOld()
+	return r.open()
+}
+
+// splitName 返回日志文件名称中扩展名以外的部分和扩展名
+func (r *RotatingFile) splitName() (base, ext string) {
+	name := filepath.Base(r.path)
+	ext = filepath.Ext(name)
+	return strings.TrimSuffix(name, ext), ext
+}
+
+// removeOld 删除超过保留时间或保留数量的轮换后的日志文件
+func (r *RotatingFile) removeOld() {
+	if r.maxAge <= 0 && r.maxBackups <= 0 {
+		return
+	}
+
+	base, ext := r.splitName()
+	entries, err := os.ReadDir(filepath.Dir(r.path))
+	if err != nil {
+		return
+	}
+	type rotatedFile struct {
+		path    string
+		modTime time.Time
+	}
+	var files []rotatedFile
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !isRotatedName(name, base, ext) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, rotatedFile{filepath.Join(filepath.Dir(r.path), name), info.ModTime()})
+	}
+
+	// 最新的在前面
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.After(files[j].modTime) })
+	for i, f := range files {
+		expired := r.maxAge > 0 && time.Since(f.modTime) > r.maxAge
+		if expired || (r.maxBackups > 0 && i >= r.maxBackups) {
+			os.Remove(f.path)
+		}
+	}
+}
+
+// isRotatedName 文件名是否为轮换后的日志文件名称 <名称>-<时间>[.<序号>]<扩展名>，
+// 避免误删同一文件夹中名称相似的其他文件
+func isRotatedName(name, base, ext string) bool {
+	prefix := base + "-"
+	if !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ext) || len(name) < len(prefix)+len(ext) {
+		return false
+	}
+	stamp := name[len(prefix) : len(name)-len(ext)]
+	if len(stamp) < len(rotateTimeFormat) {
+		return false
+	}
+	stamp, seq := stamp[:len(rotateTimeFormat)], stamp[len(rotateTimeFormat):]
+	if _, err := time.Parse(rotateTimeFormat, stamp); err != nil {
+		return false
+	}
+	if seq == "" {
+		return true
+	}
+	n, err := strconv.Atoi(strings.TrimPrefix(seq, "."))
+	return strings.HasPrefix(seq, ".") && err == nil && n > 0 && seq == "."+strconv.Itoa(n)
+}
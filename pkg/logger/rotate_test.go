@@ -0,0 +1,116 @@
+package logger
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestIsRotatedName(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"wtf-backup-2024-05-01T20-00-00.log", true},
+		{"wtf-backup-2024-05-01T20-00-00.3.log", true},
+		{"wtf-backup.log", false},
+		{"wtf-backup-old.log", false},
+		{"wtf-backup-errors-2024-05-01T20-00-00.log", false},
+		{"wtf-backup-2024-05-01T20-00-00.log.bak", false},
+		{"wtf-backup-2024-05-01T20-00-00.x.log", false},
+		{"wtf-backup-2024-05-01T20-00-00.0.log", false},
+		{"wtf-backup-2024-05-01T20-00-00.01.log", false},
+		{"wtf-backup-2024-13-01T20-00-00.log", false},
+		{"wtf-backup-2024-05-01T20-00-00-copy.log", false},
+	}
+	for _, tt := range tests {
+		if got := isRotatedName(tt.name, "wtf-backup", ".log"); got != tt.want {
+			t.Errorf("isRotatedName(%q) = %v，期望 %v", tt.name, got, tt.want)
+		}
+	}
+	if !isRotatedName("debug-2024-05-01T20-00-00", "debug", "") {
+		t.Errorf("没有扩展名的日志文件也应能识别")
+	}
+}
+
+func TestRemoveOldOnlyRemovesRotatedFiles(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	files := map[string]time.Time{
+		"wtf-backup.log":                            now,
+		"wtf-backup-2024-05-03T10-00-00.log":        now.Add(-1 * time.Hour),
+		"wtf-backup-2024-05-02T10-00-00.1.log":      now.Add(-2 * time.Hour),
+		"wtf-backup-2024-05-01T10-00-00.log":        now.Add(-3 * time.Hour),
+		"wtf-backup-old.log":                        now.Add(-48 * time.Hour),
+		"wtf-backup-errors-2024-01-01T00-00-00.log": now.Add(-48 * time.Hour),
+		"wtf-backup-2024-01-01T00-00-00.log.bak":    now.Add(-48 * time.Hour),
+	}
+	for name, modTime := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r := &RotatingFile{path: filepath.Join(dir, "wtf-backup.log"), maxBackups: 2}
+	r.removeOld()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, entry := range entries {
+		got = append(got, entry.Name())
+	}
+	want := []string{
+		"wtf-backup-2024-01-01T00-00-00.log.bak",
+		"wtf-backup-2024-05-02T10-00-00.1.log",
+		"wtf-backup-2024-05-03T10-00-00.log",
+		"wtf-backup-errors-2024-01-01T00-00-00.log",
+		"wtf-backup-old.log",
+		"wtf-backup.log",
+	}
+	sort.Strings(got)
+	if len(got) != len(want) {
+		t.Fatalf("剩余的文件为 %q，期望 %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("剩余的文件为 %q，期望 %q", got, want)
+		}
+	}
+}
+
+func TestRotatingFileRotatesBySize(t *testing.T) {
+	dir := t.TempDir()
+	r, err := OpenRotatingFile(filepath.Join(dir, "app.log"), 10, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	for i := 0; i < 3; i++ {
+		if _, err := r.Write([]byte("12345678\n")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rotated := 0
+	for _, entry := range entries {
+		if isRotatedName(entry.Name(), "app", ".log") {
+			rotated++
+		}
+	}
+	if rotated != 2 {
+		t.Errorf("轮换了 %d 次，期望 2 次", rotated)
+	}
+}
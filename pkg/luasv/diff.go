@@ -0,0 +1,146 @@
+package luasv
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ChangeKind 变化类型
+type ChangeKind int
+
+const (
+	// Added 新增的键
+	Added ChangeKind = iota
+	// Removed 删除的键
+	Removed
+	// Changed 值发生变化的键
+	Changed
+)
+
+// String 返回变化类型的符号
+func (k ChangeKind) String() string {
+	switch k {
+	case Added:
+		return "+"
+	case Removed:
+		return "-"
+	default:
+		return "~"
+	}
+}
+
+// Change 两个文件之间的一处变化
+type Change struct {
+	Kind ChangeKind
+	// 变化所在的路径，例如 WeakAurasSaved.displays["Foo"].load.class
+	Path string
+	// 旧值，新增时为 nil
+	Old Value
+	// 新值，删除时为 nil
+	New Value
+}
+
+// Diff 比较两个文件，返回所有新增、删除和变化的表路径
+// 两边都是表时逐键递归比较，因此变化会定位到最深的不同之处
+func Diff(a, b *File) []Change {
+	var changes []Change
+
+	bValues := make(map[string]Value, len(b.Assignments))
+	for _, assignment := range b.Assignments {
+		bValues[assignment.Name] = assignment.Value
+	}
+	aNames := make(map[string]bool, len(a.Assignments))
+	for _, assignment := range a.Assignments {
+		aNames[assignment.Name] = true
+		other, ok := bValues[assignment.Name]
+		if !ok {
+			changes = append(changes, Change{Kind: Removed, Path: assignment.Name, Old: assignment.Value})
+			continue
+		}
+		changes = diffValue(changes, assignment.Name, assignment.Value, other)
+	}
+	for _, assignment := range b.Assignments {
+		if !aNames[assignment.Name] {
+			changes = append(changes, Change{Kind: Added, Path: assignment.Name, New: assignment.Value})
+		}
+	}
+	return changes
+}
+
+// diffValue 比较路径 path 处的两个值
+func diffValue(changes []Change, path string, a, b Value) []Change {
+	at, aIsTable := a.(*Table)
+	bt, bIsTable := b.(*Table)
+	if !aIsTable || !bIsTable {
+		if !Equal(a, b) {
+			changes = append(changes, Change{Kind: Changed, Path: path, Old: a, New: b})
+		}
+		return changes
+	}
+
+	aEntries, bEntries := at.Entries(), bt.Entries()
+	bIndex := make(map[string]Value, len(bEntries))
+	for _, field := range bEntries {
+		bIndex[keyID(field.Key)] = field.Value
+	}
+	aKeys := make(map[string]bool, len(aEntries))
+	for _, field := range aEntries {
+		id := keyID(field.Key)
+		aKeys[id] = true
+		childPath := path + PathElement(field.Key)
+		other, ok := bIndex[id]
+		if !ok {
+			changes = append(changes, Change{Kind: Removed, Path: childPath, Old: field.Value})
+			continue
+		}
+		changes = diffValue(changes, childPath, field.Value, other)
+	}
+	for _, field := range bEntries {
+		if !aKeys[keyID(field.Key)] {
+			changes = append(changes, Change{Kind: Added, Path: path + PathElement(field.Key), New: field.Value})
+		}
+	}
+	return changes
+}
+
+// PathElement 返回访问指定键的路径片段：标识符形式的字符串键写作 .name，其余写作 [键]
+func PathElement(key Value) string {
+	switch k := key.(type) {
+	case String:
+		if isIdentifier(string(k)) {
+			return "." + string(k)
+		}
+		return "[" + Quote(string(k)) + "]"
+	case Number:
+		return "[" + string(k) + "]"
+	case Bool:
+		return "[" + strconv.FormatBool(bool(k)) + "]"
+	default:
+		return "[" + key.TypeName() + "]"
+	}
+}
+
+// Summary 返回值的简短描述，表只显示字段数量
+func Summary(v Value) string {
+	if t, ok := v.(*Table); ok {
+		if len(t.Fields) == 0 {
+			return "{}"
+		}
+		return "{...} (" + strconv.Itoa(len(t.Fields)) + " 个字段)"
+	}
+	s := FormatValue(v, 0)
+	if runes := []rune(s); len(runes) > 80 {
+		s = string(runes[:77]) + "..."
+	}
+	return s
+}
+
+// isIdentifier 是否为合法的 Lua 标识符
+func isIdentifier(s string) bool {
+	if s == "" || isKeyword(s) || !isNameStart(s[0]) {
+		return false
+	}
+	return strings.IndexFunc(s, func(r rune) bool {
+		return !(r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9'))
+	}) < 0
+}
@@ -0,0 +1,522 @@
+package luasv
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxDepth 表的最大嵌套深度，防止恶意或损坏的文件耗尽调用栈
+const maxDepth = 512
+
+// SyntaxError 语法错误
+type SyntaxError struct {
+	Line   int
+	Column int
+	Msg    string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("第 %d 行第 %d 列: %s", e.Line, e.Column, e.Msg)
+}
+
+// Parse 解析 SavedVariables 文件内容
+func Parse(data []byte) (*File, error) {
+	p := &parser{data: data, line: 1}
+	return p.parseFile()
+}
+
+// Decode 从 r 读取并解析 SavedVariables 文件
+func Decode(r io.Reader) (*File, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// parser 直接在字节切片上工作的递归下降解析器
+type parser struct {
+	data      []byte
+	pos       int
+	line      int
+	lineStart int
+	depth     int
+}
+
+// errorf 生成带当前位置的语法错误
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return &SyntaxError{
+		Line:   p.line,
+		Column: p.pos - p.lineStart + 1,
+		Msg:    fmt.Sprintf(format, args...),
+	}
+}
+
+// parseFile file := { Name '=' value [';'] }
+func (p *parser) parseFile() (*File, error) {
+	f := &File{}
+	for {
+		if err := p.skipSpace(); err != nil {
+			return nil, err
+		}
+		if p.eof() {
+			return f, nil
+		}
+
+		name, ok := p.readName()
+		if !ok {
+			return nil, p.errorf("需要变量名，遇到 %s", p.describe())
+		}
+		if isKeyword(name) {
+			return nil, p.errorf("不能对关键字 %s 赋值", name)
+		}
+		if err := p.expect('='); err != nil {
+			return nil, err
+		}
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		f.Assignments = append(f.Assignments, Assignment{Name: name, Value: value})
+
+		if err := p.skipSpace(); err != nil {
+			return nil, err
+		}
+		if p.peek() == ';' {
+			p.advance()
+		}
+	}
+}
+
+// parseValue value := nil | true | false | number | string | table
+func (p *parser) parseValue() (Value, error) {
+	if err := p.skipSpace(); err != nil {
+		return nil, err
+	}
+	if p.eof() {
+		return nil, p.errorf("文件意外结束，需要一个值")
+	}
+
+	c := p.peek()
+	switch {
+	case c == '{':
+		return p.parseTable()
+	case c == '"' || c == '\'':
+		return p.parseQuotedString()
+	case c == '[' && p.longBracketLevel() >= 0:
+		s, err := p.readLongBracket()
+		return String(s), err
+	case c == '-' || c == '.' || isDigit(c):
+		return p.parseNumber()
+	case isNameStart(c):
+		start := p.pos
+		name, _ := p.readName()
+		switch name {
+		case "nil":
+			return Nil{}, nil
+		case "true":
+			return Bool(true), nil
+		case "false":
+			return Bool(false), nil
+		}
+		p.pos = start
+		return nil, p.errorf("不支持的表达式 %s", name)
+	}
+	return nil, p.errorf("需要一个值，遇到 %s", p.describe())
+}
+
+// parseTable table := '{' [ field { sep field } [sep] ] '}'
+func (p *parser) parseTable() (Value, error) {
+	p.depth++
+	if p.depth > maxDepth {
+		return nil, p.errorf("表的嵌套层数超过 %d", maxDepth)
+	}
+	defer func() { p.depth-- }()
+
+	p.advance() // '{'
+	t := &Table{}
+	for {
+		if err := p.skipSpace(); err != nil {
+			return nil, err
+		}
+		if p.eof() {
+			return nil, p.errorf("文件意外结束，表缺少 }")
+		}
+		if p.peek() == '}' {
+			p.advance()
+			return t, nil
+		}
+
+		field, err := p.parseField()
+		if err != nil {
+			return nil, err
+		}
+		t.Fields = append(t.Fields, field)
+
+		if err := p.skipSpace(); err != nil {
+			return nil, err
+		}
+		switch p.peek() {
+		case ',', ';':
+			p.advance()
+		case '}':
+		default:
+			if p.eof() {
+				return nil, p.errorf("文件意外结束，表缺少 }")
+			}
+			return nil, p.errorf("表字段之间需要 , 或 ;，遇到 %s", p.describe())
+		}
+	}
+}
+
+// parseField field := '[' value ']' '=' value | Name '=' value | value
+func (p *parser) parseField() (Field, error) {
+	c := p.peek()
+
+	if c == '[' && p.longBracketLevel() < 0 {
+		p.advance()
+		key, err := p.parseValue()
+		if err != nil {
+			return Field{}, err
+		}
+		if _, ok := key.(Nil); ok {
+			return Field{}, p.errorf("表的键不能为 nil")
+		}
+		if _, ok := key.(*Table); ok {
+			return Field{}, p.errorf("不支持以表作为键")
+		}
+		if err := p.expect(']'); err != nil {
+			return Field{}, err
+		}
+		if err := p.expect('='); err != nil {
+			return Field{}, err
+		}
+		value, err := p.parseValue()
+		if err != nil {
+			return Field{}, err
+		}
+		return Field{Key: key, Value: value}, nil
+	}
+
+	if isNameStart(c) {
+		start, line, lineStart := p.pos, p.line, p.lineStart
+		name, _ := p.readName()
+		if !isKeyword(name) {
+			if err := p.skipSpace(); err != nil {
+				return Field{}, err
+			}
+			if p.peek() == '=' {
+				p.advance()
+				value, err := p.parseValue()
+				if err != nil {
+					return Field{}, err
+				}
+				return Field{Key: String(name), Value: value}, nil
+			}
+		}
+		p.pos, p.line, p.lineStart = start, line, lineStart
+	}
+
+	value, err := p.parseValue()
+	if err != nil {
+		return Field{}, err
+	}
+	return Field{Value: value}, nil
+}
+
+// parseNumber 解析数字，允许前导负号
+func (p *parser) parseNumber() (Value, error) {
+	start := p.pos
+	if p.peek() == '-' {
+		p.advance()
+		if err := p.skipSpace(); err != nil {
+			return nil, err
+		}
+	}
+	digitsStart := p.pos
+
+	if p.peek() == '0' && (p.peekAt(1) == 'x' || p.peekAt(1) == 'X') {
+		p.pos += 2
+		for !p.eof() && isHexDigit(p.peek()) {
+			p.pos++
+		}
+	} else {
+		for !p.eof() {
+			c := p.peek()
+			if isDigit(c) || c == '.' {
+				p.pos++
+			} else if (c == 'e' || c == 'E') && p.pos > digitsStart {
+				p.pos++
+				if p.peek() == '+' || p.peek() == '-' {
+					p.pos++
+				}
+			} else {
+				break
+			}
+		}
+	}
+
+	digits := string(p.data[digitsStart:p.pos])
+	text := digits
+	if digitsStart != start {
+		text = "-" + digits
+	}
+	n := Number(text)
+	if _, err := n.Float64(); err != nil || digits == "" {
+		literal := string(p.data[start:p.pos])
+		p.pos = start
+		return nil, p.errorf("无效的数字 %q", literal)
+	}
+	return n, nil
+}
+
+// parseQuotedString 解析以单引号或双引号包围的字符串
+func (p *parser) parseQuotedString() (Value, error) {
+	quote := p.peek()
+	p.advance()
+
+	var sb strings.Builder
+	for {
+		if p.eof() {
+			return nil, p.errorf("字符串缺少结束引号")
+		}
+		c := p.peek()
+		switch c {
+		case quote:
+			p.advance()
+			return String(sb.String()), nil
+		case '\n', '\r':
+			return nil, p.errorf("字符串中不能直接换行")
+		case '\\':
+			p.advance()
+			if err := p.readEscape(&sb); err != nil {
+				return nil, err
+			}
+		default:
+			// 快速复制一段普通字符
+			start := p.pos
+			for !p.eof() {
+				c := p.peek()
+				if c == quote || c == '\\' || c == '\n' || c == '\r' {
+					break
+				}
+				p.pos++
+			}
+			sb.Write(p.data[start:p.pos])
+		}
+	}
+}
+
+// readEscape 处理反斜杠之后的转义序列
+func (p *parser) readEscape(sb *strings.Builder) error {
+	if p.eof() {
+		return p.errorf("字符串缺少结束引号")
+	}
+	c := p.peek()
+	p.advance()
+	switch c {
+	case 'n':
+		sb.WriteByte('\n')
+	case 't':
+		sb.WriteByte('\t')
+	case 'r':
+		sb.WriteByte('\r')
+	case 'a':
+		sb.WriteByte('\a')
+	case 'b':
+		sb.WriteByte('\b')
+	case 'f':
+		sb.WriteByte('\f')
+	case 'v':
+		sb.WriteByte('\v')
+	case '\\', '"', '\'':
+		sb.WriteByte(c)
+	case '\n', '\r':
+		// 反斜杠加换行表示字符串中的换行
+		if other := p.peek(); (other == '\n' || other == '\r') && other != c {
+			p.advance()
+		}
+		p.newLine()
+		sb.WriteByte('\n')
+	case 'x':
+		if !isHexDigit(p.peek()) || !isHexDigit(p.peekAt(1)) {
+			return p.errorf("无效的转义 \\x")
+		}
+		sb.WriteByte(unhex(p.peek())<<4 | unhex(p.peekAt(1)))
+		p.pos += 2
+	default:
+		if !isDigit(c) {
+			return p.errorf("无效的转义 \\%c", c)
+		}
+		v := int(c - '0')
+		for i := 0; i < 2 && isDigit(p.peek()); i++ {
+			v = v*10 + int(p.peek()-'0')
+			p.advance()
+		}
+		if v > 255 {
+			return p.errorf("转义的字符值 %d 过大", v)
+		}
+		sb.WriteByte(byte(v))
+	}
+	return nil
+}
+
+// longBracketLevel 检查当前位置是否为长括号 [[ 或 [==[，返回等号数量，不是时返回 -1
+func (p *parser) longBracketLevel() int {
+	if p.peek() != '[' {
+		return -1
+	}
+	level := 0
+	for p.peekAt(level+1) == '=' {
+		level++
+	}
+	if p.peekAt(level+1) != '[' {
+		return -1
+	}
+	return level
+}
+
+// readLongBracket 读取长括号字符串或长注释的内容
+func (p *parser) readLongBracket() (string, error) {
+	level := p.longBracketLevel()
+	p.pos += level + 2
+	closing := "]" + strings.Repeat("=", level) + "]"
+
+	// 紧跟开括号的第一个换行不计入内容
+	if p.peek() == '\r' {
+		p.advance()
+	}
+	if p.peek() == '\n' {
+		p.advance()
+		p.newLine()
+	}
+
+	end := bytes.Index(p.data[p.pos:], []byte(closing))
+	if end < 0 {
+		return "", p.errorf("长字符串或长注释缺少 %s", closing)
+	}
+	content := p.data[p.pos : p.pos+end]
+	for i, c := range content {
+		if c == '\n' {
+			p.line++
+			p.lineStart = p.pos + i + 1
+		}
+	}
+	p.pos += end + len(closing)
+	return string(content), nil
+}
+
+// skipSpace 跳过空白和注释
+func (p *parser) skipSpace() error {
+	for !p.eof() {
+		c := p.peek()
+		switch {
+		case c == '\n':
+			p.advance()
+			p.newLine()
+		case c == ' ' || c == '\t' || c == '\r' || c == '\f' || c == '\v':
+			p.advance()
+		case c == '-' && p.peekAt(1) == '-':
+			p.pos += 2
+			if p.longBracketLevel() >= 0 {
+				if _, err := p.readLongBracket(); err != nil {
+					return err
+				}
+				continue
+			}
+			for !p.eof() && p.peek() != '\n' {
+				p.advance()
+			}
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+// expect 跳过空白后读取指定字符
+func (p *parser) expect(c byte) error {
+	if err := p.skipSpace(); err != nil {
+		return err
+	}
+	if p.peek() != c || p.eof() {
+		return p.errorf("需要 %c，遇到 %s", c, p.describe())
+	}
+	p.advance()
+	return nil
+}
+
+// readName 读取标识符
+func (p *parser) readName() (string, bool) {
+	if p.eof() || !isNameStart(p.peek()) {
+		return "", false
+	}
+	start := p.pos
+	for !p.eof() && (isNameStart(p.peek()) || isDigit(p.peek())) {
+		p.pos++
+	}
+	return string(p.data[start:p.pos]), true
+}
+
+// describe 描述当前位置的内容，用于错误信息
+func (p *parser) describe() string {
+	if p.eof() {
+		return "文件结尾"
+	}
+	r, _ := utf8.DecodeRune(p.data[p.pos:])
+	return fmt.Sprintf("%q", r)
+}
+
+func (p *parser) eof() bool { return p.pos >= len(p.data) }
+
+func (p *parser) peek() byte { return p.peekAt(0) }
+
+func (p *parser) peekAt(offset int) byte {
+	if p.pos+offset >= len(p.data) {
+		return 0
+	}
+	return p.data[p.pos+offset]
+}
+
+func (p *parser) advance() { p.pos++ }
+
+func (p *parser) newLine() {
+	p.line++
+	p.lineStart = p.pos
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+func isHexDigit(c byte) bool {
+	return isDigit(c) || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+func unhex(c byte) byte {
+	switch {
+	case isDigit(c):
+		return c - '0'
+	case c >= 'a' && c <= 'f':
+		return c - 'a' + 10
+	default:
+		return c - 'A' + 10
+	}
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// keywords 不能作为变量名或字段名的 Lua 关键字
+var keywords = map[string]bool{
+	"and": true, "break": true, "do": true, "else": true, "elseif": true, "end": true,
+	"false": true, "for": true, "function": true, "goto": true, "if": true, "in": true,
+	"local": true, "nil": true, "not": true, "or": true, "repeat": true, "return": true,
+	"then": true, "true": true, "until": true, "while": true,
+}
+
+func isKeyword(name string) bool {
+	return keywords[name]
+}
@@ -0,0 +1,182 @@
+package luasv
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestParseValues(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  Value
+	}{
+		{"双引号转义", `A = "a\"b\\c\n\t\65\x41\'"`, String("a\"b\\c\n\tAA'")},
+		{"单引号", `A = 'say "hi"'`, String(`say "hi"`)},
+		{"反斜杠换行", "A = \"a\\\nb\"", String("a\nb")},
+		{"三位十进制转义", `A = "\0011"`, String("\x011")},
+		{"长括号", "A = [[\nline1\nline2]]", String("line1\nline2")},
+		{"带等号的长括号", "A = [==[a]]b]=]c]==]", String("a]]b]=]c")},
+		{"空的长括号", "A = [[]]", String("")},
+		{"负数", "A = -5", Number("-5")},
+		{"负号后有空格", "A = - 5", Number("-5")},
+		{"十六进制", "A = 0x1F", Number("0x1F")},
+		{"负的十六进制", "A = -0xff", Number("-0xff")},
+		{"指数", "A = 1.5e-3", Number("1.5e-3")},
+		{"大写指数", "A = 2E+10", Number("2E+10")},
+		{"小数点开头", "A = .5", Number(".5")},
+		{"nil", "A = nil", Nil{}},
+		{"布尔值", "A = false", Bool(false)},
+		{
+			"嵌套表",
+			`A = { ["x"] = { 1, "two", { y = true } }, z = { }, [3] = nil; }`,
+			&Table{Fields: []Field{
+				{Key: String("x"), Value: &Table{Fields: []Field{
+					{Value: Number("1")},
+					{Value: String("two")},
+					{Value: &Table{Fields: []Field{{Key: String("y"), Value: Bool(true)}}}},
+				}}},
+				{Key: String("z"), Value: &Table{}},
+				{Key: Number("3"), Value: Nil{}},
+			}},
+		},
+		{
+			"重复的键以最后一次赋值为准",
+			`A = { x = 1, ["x"] = 2, "a", [1] = "b" }`,
+			&Table{Fields: []Field{
+				{Key: String("x"), Value: Number("2")},
+				{Key: Number("1"), Value: String("b")},
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := Parse([]byte(tt.input))
+			if err != nil {
+				t.Fatalf("解析失败: %v", err)
+			}
+			got, ok := f.Lookup("A")
+			if !ok {
+				t.Fatalf("没有找到变量 A")
+			}
+			if !Equal(got, tt.want) {
+				t.Errorf("解析结果为 %s，期望 %s", FormatValue(got, 0), FormatValue(tt.want, 0))
+			}
+		})
+	}
+}
+
+func TestParseNumberValues(t *testing.T) {
+	tests := []struct {
+		input string
+		want  float64
+	}{
+		{"0x1F", 31},
+		{"-0xff", -255},
+		{"1.5e-3", 0.0015},
+		{"2E+10", 2e10},
+		{"-5", -5},
+		{".5", 0.5},
+	}
+	for _, tt := range tests {
+		got, err := Number(tt.input).Float64()
+		if err != nil || got != tt.want {
+			t.Errorf("Number(%q).Float64() = %v, %v，期望 %v", tt.input, got, err, tt.want)
+		}
+	}
+	if !Equal(Number("0x10"), Number("16")) {
+		t.Errorf("写法不同但数值相同的数字应相等")
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	inputs := []string{
+		`A = "abc`,
+		"A = \"a\nb\"",
+		`A = "\q"`,
+		`A = "\256"`,
+		"A = [==[abc]=]",
+		"A = { 1, 2",
+		"A = { [nil] = 1 }",
+		"A = 0x",
+		"A = -",
+		"nil = 1",
+		"A = foo",
+	}
+	for _, input := range inputs {
+		if _, err := Parse([]byte(input)); err == nil {
+			t.Errorf("解析 %q 应失败", input)
+		}
+	}
+}
+
+func TestGameFormatRoundTrip(t *testing.T) {
+	input := "\nAddonDB = {\n\t[\"profiles\"] = {\n\t\t[\"Default\"] = {\n\t\t\t[\"scale\"] = 1.25,\n\t\t\t[\"enabled\"] = true,\n\t\t},\n\t},\n\t[\"list\"] = {\n\t\t\"a\\\"b\", -- [1]\n\t\t-3, -- [2]\n\t},\n}\nAddonPerChar = nil\n"
+	f, err := Parse([]byte(input))
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	if out := f.Bytes(); string(out) != input {
+		t.Errorf("写回的内容与原文件不同:\n%s\n期望:\n%s", out, input)
+	}
+}
+
+func TestEqualDuplicateKeys(t *testing.T) {
+	f, err := Parse([]byte(`A0={A='00',A=[[]]}`))
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	v, _ := f.Lookup("A0")
+	if !Equal(v, v) {
+		t.Errorf("有重复键的表应与自身相等")
+	}
+	if !Equal(v, &Table{Fields: []Field{{Key: String("A"), Value: String("")}}}) {
+		t.Errorf("重复的键应以最后一次赋值为准")
+	}
+	if Equal(v, &Table{Fields: []Field{{Key: String("A"), Value: String("00")}}}) {
+		t.Errorf("被覆盖的赋值不应参与比较")
+	}
+	if got := v.(*Table).Get(String("A")); !Equal(got, String("")) {
+		t.Errorf("Get 返回 %v，期望最后一次赋值的值", got)
+	}
+}
+
+func FuzzRoundTrip(f *testing.F) {
+	seeds := []string{
+		"",
+		`A = "a\"b\\c\n\t\65\x41"`,
+		"A = [==[a]]b]==]",
+		`A = { ["x"] = { 1, "two", { y = true } }, z = {}, [3] = nil; }`,
+		"A = -0x1F B = 1.5e-3; C = - 5",
+		`A0={A='00',A=[[]]}`,
+		"-- 注释\nA = --[[ 长注释 ]] { [true] = false, [-1] = .5 }",
+	}
+	for _, seed := range seeds {
+		f.Add([]byte(seed))
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		file, err := Parse(data)
+		if err != nil {
+			return
+		}
+		out := file.Bytes()
+		again, err := Parse(out)
+		if err != nil {
+			t.Fatalf("重新解析写出的内容失败: %v\n%s", err, out)
+		}
+		if out2 := again.Bytes(); !bytes.Equal(out, out2) {
+			t.Fatalf("第二次写出的内容不同:\n%s\n第一次:\n%s", out2, out)
+		}
+		if len(file.Assignments) != len(again.Assignments) {
+			t.Fatalf("赋值数量从 %d 变为 %d", len(file.Assignments), len(again.Assignments))
+		}
+		for i, a := range file.Assignments {
+			b := again.Assignments[i]
+			if a.Name != b.Name || !Equal(a.Value, b.Value) {
+				t.Fatalf("第 %d 个赋值 %s 往返后不相等", i+1, a.Name)
+			}
+		}
+	})
+}
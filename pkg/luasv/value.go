@@ -0,0 +1,197 @@
+// Package luasv 读写魔兽世界 SavedVariables 文件
+//
+// 游戏写入的 SavedVariables 只使用 Lua 的一个很小的子集：若干个全局变量赋值，
+// 值为嵌套的表、字符串、数字、布尔值或 nil。本包将文件解析为有序的值树，
+// 并按照游戏自身的格式（制表符缩进、["键"] = 值、数组元素后附 -- [序号]）写回，
+// 因此由游戏写出的文件可以逐字节往返。
+package luasv
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Value Lua 值，具体类型为 Nil、Bool、Number、String 或 *Table
+type Value interface {
+	// TypeName 返回 Lua 类型名称
+	TypeName() string
+}
+
+// Nil Lua 的 nil
+type Nil struct{}
+
+// Bool Lua 的布尔值
+type Bool bool
+
+// Number Lua 的数字，保留原始写法以便逐字节写回
+type Number string
+
+// String Lua 的字符串（已处理转义）
+type String string
+
+// Table Lua 的表，字段保持文件中的顺序
+type Table struct {
+	Fields []Field
+}
+
+// Field 表中的一个字段，Key 为 nil 时表示按位置排列的数组元素
+type Field struct {
+	Key   Value
+	Value Value
+}
+
+// Assignment 文件中的一个全局变量赋值
+type Assignment struct {
+	Name  string
+	Value Value
+}
+
+// File 一个 SavedVariables 文件
+type File struct {
+	Assignments []Assignment
+}
+
+func (Nil) TypeName() string    { return "nil" }
+func (Bool) TypeName() string   { return "boolean" }
+func (Number) TypeName() string { return "number" }
+func (String) TypeName() string { return "string" }
+func (*Table) TypeName() string { return "table" }
+
+// Float64 返回数字的浮点值
+func (n Number) Float64() (float64, error) {
+	s := string(n)
+	neg := strings.HasPrefix(s, "-")
+	if neg {
+		s = s[1:]
+	}
+	var v float64
+	var err error
+	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
+		var u uint64
+		u, err = strconv.ParseUint(s[2:], 16, 64)
+		v = float64(u)
+	} else {
+		v, err = strconv.ParseFloat(s, 64)
+	}
+	if neg {
+		v = -v
+	}
+	return v, err
+}
+
+// NewNumber 按游戏的写法（最多14位有效数字）创建数字
+func NewNumber(v float64) Number {
+	return Number(strconv.FormatFloat(v, 'g', 14, 64))
+}
+
+// Get 返回指定键对应的值，不存在时返回 nil，重复的键以最后一次赋值为准
+// 数组元素可以用 Number 类型的序号访问
+func (t *Table) Get(key Value) Value {
+	var value Value
+	index := 0
+	for _, field := range t.Fields {
+		k := field.Key
+		if k == nil {
+			index++
+			k = Number(strconv.Itoa(index))
+		}
+		if Equal(k, key) {
+			value = field.Value
+		}
+	}
+	return value
+}
+
+// Entries 返回所有字段，数组元素的键替换为对应的序号
+func (t *Table) Entries() []Field {
+	entries := make([]Field, len(t.Fields))
+	index := 0
+	for i, field := range t.Fields {
+		if field.Key == nil {
+			index++
+			field.Key = Number(strconv.Itoa(index))
+		}
+		entries[i] = field
+	}
+	return entries
+}
+
+// Lookup 返回全局变量的值
+func (f *File) Lookup(name string) (Value, bool) {
+	for _, a := range f.Assignments {
+		if a.Name == name {
+			return a.Value, true
+		}
+	}
+	return nil, false
+}
+
+// Equal 比较两个值是否相等，表按内容递归比较，数字按数值比较
+func Equal(a, b Value) bool {
+	switch av := a.(type) {
+	case Nil:
+		_, ok := b.(Nil)
+		return ok
+	case Bool:
+		bv, ok := b.(Bool)
+		return ok && av == bv
+	case Number:
+		bv, ok := b.(Number)
+		if !ok {
+			return false
+		}
+		if av == bv {
+			return true
+		}
+		af, errA := av.Float64()
+		bf, errB := bv.Float64()
+		return errA == nil && errB == nil && af == bf
+	case String:
+		bv, ok := b.(String)
+		return ok && av == bv
+	case *Table:
+		bv, ok := b.(*Table)
+		if !ok {
+			return false
+		}
+		am, bm := av.entryMap(), bv.entryMap()
+		if len(am) != len(bm) {
+			return false
+		}
+		for id, value := range am {
+			other, ok := bm[id]
+			if !ok || !Equal(value, other) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// entryMap 返回按键规范标识索引的字段值，重复的键与 Lua 一样以最后一次赋值为准
+func (t *Table) entryMap() map[string]Value {
+	entries := t.Entries()
+	m := make(map[string]Value, len(entries))
+	for _, field := range entries {
+		m[keyID(field.Key)] = field.Value
+	}
+	return m
+}
+
+// keyID 返回键的规范标识，用于按键比较表的内容
+func keyID(key Value) string {
+	switch k := key.(type) {
+	case String:
+		return "s:" + string(k)
+	case Number:
+		if f, err := k.Float64(); err == nil {
+			return "n:" + strconv.FormatFloat(f, 'g', -1, 64)
+		}
+		return "n:" + string(k)
+	case Bool:
+		return "b:" + strconv.FormatBool(bool(k))
+	default:
+		return key.TypeName()
+	}
+}
@@ -0,0 +1,130 @@
+package luasv
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Bytes 按游戏的格式写出文件内容
+func (f *File) Bytes() []byte {
+	var buf bytes.Buffer
+	f.WriteTo(&buf)
+	return buf.Bytes()
+}
+
+// WriteTo 按游戏的格式写出文件：
+//
+//	(空行)
+//	Name = {
+//		["key"] = "value",
+//		["list"] = {
+//			"a", -- [1]
+//		},
+//	}
+func (f *File) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: bufio.NewWriter(w)}
+	cw.WriteString("\n")
+	for _, a := range f.Assignments {
+		cw.WriteString(a.Name)
+		cw.WriteString(" = ")
+		writeValue(cw, a.Value, 0)
+		cw.WriteString("\n")
+	}
+	if cw.err == nil {
+		cw.err = cw.w.Flush()
+	}
+	return cw.n, cw.err
+}
+
+// FormatValue 按游戏的格式写出单个值，indent 为其所在行的缩进层级
+func FormatValue(v Value, indent int) string {
+	var buf bytes.Buffer
+	cw := &countingWriter{w: bufio.NewWriter(&buf)}
+	writeValue(cw, v, indent)
+	cw.w.Flush()
+	return buf.String()
+}
+
+// writeValue 写出一个值
+func writeValue(w *countingWriter, v Value, indent int) {
+	switch v := v.(type) {
+	case *Table:
+		w.WriteString("{\n")
+		index := 0
+		for _, field := range v.Fields {
+			w.WriteString(strings.Repeat("\t", indent+1))
+			if field.Key == nil {
+				index++
+				writeValue(w, field.Value, indent+1)
+				w.WriteString(", -- [")
+				w.WriteString(strconv.Itoa(index))
+				w.WriteString("]\n")
+				continue
+			}
+			w.WriteString("[")
+			writeValue(w, field.Key, indent+1)
+			w.WriteString("] = ")
+			writeValue(w, field.Value, indent+1)
+			w.WriteString(",\n")
+		}
+		w.WriteString(strings.Repeat("\t", indent))
+		w.WriteString("}")
+	case String:
+		w.WriteString(Quote(string(v)))
+	case Number:
+		w.WriteString(string(v))
+	case Bool:
+		w.WriteString(strconv.FormatBool(bool(v)))
+	default:
+		w.WriteString("nil")
+	}
+}
+
+// Quote 按游戏的写法为字符串加上双引号并转义
+func Quote(s string) string {
+	var sb strings.Builder
+	sb.Grow(len(s) + 2)
+	sb.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch c {
+		case '"':
+			sb.WriteString(`\"`)
+		case '\\':
+			sb.WriteString(`\\`)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\r':
+			sb.WriteString(`\r`)
+		default:
+			if c < 0x20 || c == 0x7f {
+				// 使用三位十进制转义，避免与后面的数字连在一起
+				sb.WriteByte('\\')
+				sb.WriteString(strconv.FormatInt(int64(c)+1000, 10)[1:])
+				continue
+			}
+			sb.WriteByte(c)
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
+
+// countingWriter 记录写入字节数并保留第一个错误
+type countingWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (cw *countingWriter) WriteString(s string) {
+	if cw.err != nil {
+		return
+	}
+	n, err := cw.w.WriteString(s)
+	cw.n += int64(n)
+	cw.err = err
+}
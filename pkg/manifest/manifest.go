@@ -0,0 +1,121 @@
+package manifest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Version 当前清单格式版本
+const Version = 1
+
+// Entry 清单中的一个文件
+type Entry struct {
+	// 相对于WTF文件夹的路径，使用 '/' 分隔
+	Path string `json:"path"`
+	// 文件大小
+	Size int64 `json:"size"`
+	// 文件权限
+	Mode fs.FileMode `json:"mode"`
+	// 修改时间
+	ModTime time.Time `json:"mtime"`
+	// 文件内容的 SHA-256（十六进制）
+	SHA256 string `json:"sha256"`
+}
+
+// Manifest 一个备份中所有文件的清单
+type Manifest struct {
+	Version int       `json:"version"`
+	Created time.Time `json:"created"`
+	Files   []Entry   `json:"files"`
+}
+
+// New 创建空清单
+func New() *Manifest {
+	return &Manifest{
+		Version: Version,
+		Created: time.Now(),
+	}
+}
+
+// Add 添加文件条目
+func (m *Manifest) Add(entry Entry) {
+	m.Files = append(m.Files, entry)
+}
+
+// Sort 按路径排序
+func (m *Manifest) Sort() {
+	sort.Slice(m.Files, func(i, j int) bool {
+		return m.Files[i].Path < m.Files[j].Path
+	})
+}
+
+// Index 返回 路径 -> 条目 的索引
+func (m *Manifest) Index() map[string]Entry {
+	index := make(map[string]Entry, len(m.Files))
+	for _, entry := range m.Files {
+		index[entry.Path] = entry
+	}
+	return index
+}
+
+// TotalSize 返回所有文件的总大小
+func (m *Manifest) TotalSize() int64 {
+	var total int64
+	for _, entry := range m.Files {
+		total += entry.Size
+	}
+	return total
+}
+
+// Load 从文件读取清单
+func Load(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取清单失败: %w", err)
+	}
+
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("解析清单 %s 失败: %w", path, err)
+	}
+	if m.Version > Version {
+		return nil, fmt.Errorf("清单 %s 的版本 %d 过新，当前仅支持版本 %d", path, m.Version, Version)
+	}
+	return &m, nil
+}
+
+// Save 将清单写入文件，先写入临时文件再重命名，避免留下不完整的清单
+func (m *Manifest) Save(path string) error {
+	m.Sort()
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化清单失败: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("创建清单文件失败: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return fmt.Errorf("设置清单权限失败: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("写入清单失败: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("写入清单失败: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("保存清单失败: %w", err)
+	}
+	return nil
+}
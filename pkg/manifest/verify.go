@@ -0,0 +1,121 @@
+package manifest
+
+import (
+	"fmt"
+	"io/fs"
+
+	"github.com/lizhening/WtfBackup/pkg/store"
+)
+
+// Build 遍历文件系统并计算每个文件的 SHA-256，生成清单
+func Build(fsys fs.FS) (*Manifest, error) {
+	m := New()
+	err := fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !d.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		hash, err := hashFile(fsys, path)
+		if err != nil {
+			return fmt.Errorf("计算 %s 的哈希失败: %w", path, err)
+		}
+
+		m.Add(Entry{
+			Path:    path,
+			Size:    info.Size(),
+			Mode:    info.Mode().Perm(),
+			ModTime: info.ModTime(),
+			SHA256:  hash,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	m.Sort()
+	return m, nil
+}
+
+// Report 校验结果
+type Report struct {
+	// 清单中有但备份中缺失的文件
+	Missing []string
+	// 备份中有但清单中没有的文件
+	Extra []string
+	// 大小或内容与清单不符的文件
+	Corrupted []string
+	// 校验通过的文件数量
+	Verified int
+}
+
+// OK 备份是否与清单完全一致
+func (r *Report) OK() bool {
+	return len(r.Missing) == 0 && len(r.Extra) == 0 && len(r.Corrupted) == 0
+}
+
+// Verify 重新计算文件系统中每个文件的哈希，并与清单比较
+func Verify(fsys fs.FS, m *Manifest) (*Report, error) {
+	report := &Report{}
+	expected := m.Index()
+	seen := make(map[string]bool, len(expected))
+
+	err := fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !d.Type().IsRegular() {
+			return nil
+		}
+
+		entry, ok := expected[path]
+		if !ok {
+			report.Extra = append(report.Extra, path)
+			return nil
+		}
+		seen[path] = true
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if info.Size() != entry.Size {
+			report.Corrupted = append(report.Corrupted, path)
+			return nil
+		}
+
+		hash, err := hashFile(fsys, path)
+		if err != nil || hash != entry.SHA256 {
+			report.Corrupted = append(report.Corrupted, path)
+			return nil
+		}
+		report.Verified++
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range m.Files {
+		if !seen[entry.Path] {
+			report.Missing = append(report.Missing, entry.Path)
+		}
+	}
+	return report, nil
+}
+
+// hashFile 计算文件系统中单个文件的 SHA-256
+func hashFile(fsys fs.FS, path string) (string, error) {
+	f, err := fsys.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	return store.Hash(f)
+}
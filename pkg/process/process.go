@@ -0,0 +1,223 @@
+package process
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/lizhening/WtfBackup/pkg/logger"
+)
+
+// GameProcesses 魔兽世界各个版本的进程名称
+var GameProcesses = []string{
+	"Wow.exe",
+	"Wow-64.exe",
+	"WowClassic.exe",
+	"WowClassicT.exe",
+	"WowClassicB.exe",
+	"WowT.exe",
+	"WowB.exe",
+	// macOS
+	"World of Warcraft",
+	"World of Warcraft Classic",
+}
+
+// ErrGameRunning 游戏正在运行
+var ErrGameRunning = errors.New("魔兽世界正在运行")
+
+// Lister 列出正在运行的进程名称，测试时可以替换为固定的进程列表
+type Lister interface {
+	List() ([]string, error)
+}
+
+// System 通过操作系统列出进程
+type System struct{}
+
+// Default 默认使用的进程列表
+var Default Lister = System{}
+
+// Policy 备份时游戏正在运行的处理方式
+type Policy string
+
+const (
+	// PolicyWarn 给出警告后继续备份
+	PolicyWarn Policy = "warn"
+	// PolicyWait 等待游戏退出后再备份
+	PolicyWait Policy = "wait"
+	// PolicyAbort 取消备份
+	PolicyAbort Policy = "abort"
+)
+
+// ParsePolicy 解析处理方式，空字符串视为 warn
+func ParsePolicy(s string) (Policy, error) {
+	switch p := Policy(strings.ToLower(strings.TrimSpace(s))); p {
+	case "":
+		return PolicyWarn, nil
+	case PolicyWarn, PolicyWait, PolicyAbort:
+		return p, nil
+	default:
+		return "", fmt.Errorf("无效的处理方式 %q (可选: warn, wait, abort)", s)
+	}
+}
+
+// FindGame 返回正在运行的游戏进程名称，lister 为 nil 时使用 Default
+func FindGame(lister Lister) ([]string, error) {
+	if lister == nil {
+		lister = Default
+	}
+	names, err := lister.List()
+	if err != nil {
+		return nil, err
+	}
+
+	// 同一个进程可能以进程名和可执行文件名出现两次
+	var found []string
+	seen := make(map[string]bool)
+	for _, name := range names {
+		for _, game := range GameProcesses {
+			if strings.EqualFold(name, game) && !seen[game] {
+				seen[game] = true
+				found = append(found, name)
+			}
+		}
+	}
+	return found, nil
+}
+
+// CheckNotRunning 游戏正在运行时返回 ErrGameRunning，无法列出进程时只记录日志并视为没有运行
+func CheckNotRunning(lister Lister) error {
+	found, err := FindGame(lister)
+	if err != nil {
+		logger.Warn("检查游戏是否正在运行失败: %v", err)
+		return nil
+	}
+	if len(found) > 0 {
+		return fmt.Errorf("%w (%s)", ErrGameRunning, strings.Join(found, ", "))
+	}
+	return nil
+}
+
+// CheckBeforeWrite 写入WTF文件夹之前检查游戏是否正在运行：
+// 游戏退出或重载界面时会用内存中的设置覆盖写入的文件。allow 为 true 时只给出警告
+func CheckBeforeWrite(lister Lister, allow bool) error {
+	err := CheckNotRunning(lister)
+	if err == nil {
+		return nil
+	}
+	if allow {
+		logger.Warn("%v，游戏退出或重载界面时可能会覆盖写入的文件", err)
+		return nil
+	}
+	return fmt.Errorf("%w，游戏退出时会覆盖写入的文件，请先退出游戏 (使用 -allow-running 强制执行)", err)
+}
+
+// Wait 每隔 interval 检查一次，直到游戏退出或 ctx 被取消
+func Wait(ctx context.Context, lister Lister, interval time.Duration) error {
+	logged := false
+	for {
+		err := CheckNotRunning(lister)
+		if err == nil {
+			if logged {
+				logger.Info("游戏已退出")
+			}
+			return nil
+		}
+		if !logged {
+			logger.Info("%v，等待游戏退出...", err)
+			logged = true
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+// List 通过操作系统返回正在运行的进程名称
+func (System) List() ([]string, error) {
+	switch runtime.GOOS {
+	case "windows":
+		return listTasklist()
+	case "linux":
+		return listProc()
+	default:
+		return listPs()
+	}
+}
+
+// listTasklist 通过 tasklist 列出 Windows 上的进程
+func listTasklist() ([]string, error) {
+	out, err := exec.Command("tasklist", "/FO", "CSV", "/NH").Output()
+	if err != nil {
+		return nil, fmt.Errorf("运行 tasklist 失败: %w", err)
+	}
+	records, err := csv.NewReader(bytes.NewReader(out)).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("解析 tasklist 的输出失败: %w", err)
+	}
+
+	var names []string
+	for _, record := range records {
+		if len(record) > 0 {
+			names = append(names, record[0])
+		}
+	}
+	return names, nil
+}
+
+// listProc 读取 /proc 列出 Linux 上的进程
+// 通过 Wine 运行的游戏进程名称会被截断，因此同时读取命令行中的可执行文件名称
+func listProc() ([]string, error) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, fmt.Errorf("读取 /proc 失败: %w", err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.Trim(entry.Name(), "0123456789") != "" {
+			continue
+		}
+		dir := filepath.Join("/proc", entry.Name())
+		if comm, err := os.ReadFile(filepath.Join(dir, "comm")); err == nil {
+			names = append(names, strings.TrimSpace(string(comm)))
+		}
+		if cmdline, err := os.ReadFile(filepath.Join(dir, "cmdline")); err == nil && len(cmdline) > 0 {
+			exe, _, _ := strings.Cut(string(cmdline), "\x00")
+			// Wine 中的路径使用反斜杠
+			exe = exe[strings.LastIndexAny(exe, `/\`)+1:]
+			if exe != "" {
+				names = append(names, exe)
+			}
+		}
+	}
+	return names, nil
+}
+
+// listPs 通过 ps 列出 macOS 等系统上的进程
+func listPs() ([]string, error) {
+	out, err := exec.Command("ps", "-axo", "comm=").Output()
+	if err != nil {
+		return nil, fmt.Errorf("运行 ps 失败: %w", err)
+	}
+
+	var names []string
+	for _, line := range strings.Split(string(out), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		names = append(names, filepath.Base(line))
+	}
+	return names, nil
+}
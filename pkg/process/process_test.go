@@ -0,0 +1,113 @@
+package process
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// fakeLister 返回固定的进程列表
+type fakeLister struct {
+	names []string
+	err   error
+}
+
+func (l fakeLister) List() ([]string, error) {
+	return l.names, l.err
+}
+
+// sequenceLister 每次调用依次返回下一个进程列表，用完后一直返回最后一个
+type sequenceLister struct {
+	lists [][]string
+	calls int
+}
+
+func (l *sequenceLister) List() ([]string, error) {
+	i := l.calls
+	if i >= len(l.lists) {
+		i = len(l.lists) - 1
+	}
+	l.calls++
+	return l.lists[i], nil
+}
+
+func TestFindGame(t *testing.T) {
+	tests := []struct {
+		name  string
+		names []string
+		want  []string
+	}{
+		{"没有游戏", []string{"bash", "explorer.exe"}, nil},
+		// Linux 上同一个进程的 comm 和命令行中的可执行文件名称都会列出
+		{"进程名和可执行文件名重复", []string{"Wow.exe", "bash", "Wow.exe"}, []string{"Wow.exe"}},
+		{"忽略大小写", []string{"wow.exe", "WOW.EXE"}, []string{"wow.exe"}},
+		{"多个版本", []string{"Wow.exe", "WowClassic.exe", "WowClassic.exe"}, []string{"Wow.exe", "WowClassic.exe"}},
+		{"macOS", []string{"World of Warcraft"}, []string{"World of Warcraft"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FindGame(fakeLister{names: tt.names})
+			if err != nil {
+				t.Fatalf("FindGame 失败: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FindGame = %q，期望 %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckNotRunning(t *testing.T) {
+	if err := CheckNotRunning(fakeLister{names: []string{"bash"}}); err != nil {
+		t.Errorf("游戏没有运行时返回了 %v", err)
+	}
+	if err := CheckNotRunning(fakeLister{names: []string{"Wow.exe"}}); !errors.Is(err, ErrGameRunning) {
+		t.Errorf("游戏正在运行时返回 %v，期望 ErrGameRunning", err)
+	}
+	if err := CheckNotRunning(fakeLister{err: errors.New("无法列出进程")}); err != nil {
+		t.Errorf("无法列出进程时应视为没有运行，返回了 %v", err)
+	}
+}
+
+func TestCheckBeforeWrite(t *testing.T) {
+	running := fakeLister{names: []string{"Wow.exe"}}
+	if err := CheckBeforeWrite(running, false); !errors.Is(err, ErrGameRunning) {
+		t.Errorf("不允许时返回 %v，期望 ErrGameRunning", err)
+	}
+	if err := CheckBeforeWrite(running, true); err != nil {
+		t.Errorf("允许时只应给出警告，返回了 %v", err)
+	}
+	if err := CheckBeforeWrite(fakeLister{}, false); err != nil {
+		t.Errorf("游戏没有运行时返回了 %v", err)
+	}
+}
+
+func TestParsePolicy(t *testing.T) {
+	tests := map[string]Policy{"": PolicyWarn, "warn": PolicyWarn, " Wait ": PolicyWait, "ABORT": PolicyAbort}
+	for input, want := range tests {
+		if got, err := ParsePolicy(input); err != nil || got != want {
+			t.Errorf("ParsePolicy(%q) = %q, %v，期望 %q", input, got, err, want)
+		}
+	}
+	if _, err := ParsePolicy("ignore"); err == nil {
+		t.Errorf("ParsePolicy 应拒绝无效的处理方式")
+	}
+}
+
+func TestWait(t *testing.T) {
+	lister := &sequenceLister{lists: [][]string{{"Wow.exe"}, {"Wow.exe"}, {"bash"}}}
+	if err := Wait(context.Background(), lister, time.Millisecond); err != nil {
+		t.Fatalf("游戏退出后 Wait 返回了 %v", err)
+	}
+	if lister.calls != 3 {
+		t.Errorf("检查了 %d 次，期望 3 次", lister.calls)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := Wait(ctx, fakeLister{names: []string{"Wow.exe"}}, time.Hour); !errors.Is(err, context.Canceled) {
+		t.Errorf("ctx 被取消后 Wait 返回 %v，期望 context.Canceled", err)
+	}
+}
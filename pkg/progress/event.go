@@ -0,0 +1,126 @@
+package progress
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// EventType 事件类型
+type EventType string
+
+const (
+	// EventStart 操作开始
+	EventStart EventType = "start"
+	// EventProgress 已完成的字节数和文件数
+	EventProgress EventType = "progress"
+	// EventFile 复制或恢复了一个文件
+	EventFile EventType = "file"
+	// EventSkip 跳过了一个文件
+	EventSkip EventType = "skip"
+	// EventRemove 删除了一个旧备份
+	EventRemove EventType = "remove"
+	// EventWarning 警告
+	EventWarning EventType = "warning"
+	// EventError 错误
+	EventError EventType = "error"
+	// EventDone 操作结束，包含汇总信息
+	EventDone EventType = "done"
+)
+
+// Event 备份、恢复和清理旧备份过程中发布的事件
+type Event struct {
+	Time time.Time `json:"time"`
+	Type EventType `json:"type"`
+	// 操作名称，例如 backup、restore、prune
+	Op string `json:"op,omitempty"`
+	// 相关的文件或备份路径
+	Path string `json:"path,omitempty"`
+	// 文件或备份的大小
+	Size int64 `json:"size,omitempty"`
+	// 已完成的字节数和总字节数
+	Bytes      int64 `json:"bytes,omitempty"`
+	TotalBytes int64 `json:"total_bytes,omitempty"`
+	// 已完成的文件数和文件总数
+	Files      int `json:"files,omitempty"`
+	TotalFiles int `json:"total_files,omitempty"`
+	// 说明，例如警告内容或跳过的原因
+	Message string `json:"message,omitempty"`
+	// 操作结束时的汇总
+	Summary *Summary `json:"summary,omitempty"`
+}
+
+// Summary 操作结束时的汇总信息
+type Summary struct {
+	// 备份名称
+	Backup  string `json:"backup,omitempty"`
+	Files   int    `json:"files"`
+	Bytes   int64  `json:"bytes"`
+	Skipped int    `json:"skipped,omitempty"`
+	Removed int    `json:"removed,omitempty"`
+	// 耗时（秒）
+	Duration float64 `json:"duration_seconds"`
+	// 失败时的错误信息
+	Error string `json:"error,omitempty"`
+}
+
+// Sink 接收事件，例如输出给图形界面或脚本
+type Sink interface {
+	Emit(e Event)
+}
+
+// JSONSink 将每个事件作为一行 JSON 写入 w
+type JSONSink struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewJSONSink 创建 JSON 事件输出
+func NewJSONSink(w io.Writer) *JSONSink {
+	return &JSONSink{enc: json.NewEncoder(w)}
+}
+
+// Emit 实现 Sink 接口
+func (s *JSONSink) Emit(e Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.enc.Encode(e)
+}
+
+// 全局默认的事件接收者，为 nil 时不发布事件
+var defaultSink Sink
+
+// SetDefaultSink 设置默认的事件接收者，设置后进度条不再输出到终端，而是发布进度事件
+func SetDefaultSink(sink Sink) {
+	defaultSink = sink
+}
+
+// Enabled 是否设置了事件接收者
+func Enabled() bool {
+	return defaultSink != nil
+}
+
+// Emit 向默认的事件接收者发布事件，没有设置接收者时忽略
+func Emit(e Event) {
+	emitTo(defaultSink, e)
+}
+
+// emitTo 向 sink 发布事件，补充事件时间
+func emitTo(sink Sink, e Event) {
+	if sink == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	sink.Emit(e)
+}
+
+// Done 生成操作结束事件，err 不为 nil 时记录在汇总中
+func Done(op string, summary Summary, err error) Event {
+	if err != nil {
+		summary.Error = err.Error()
+	}
+	return Event{Type: EventDone, Op: op, Summary: &summary}
+}
@@ -0,0 +1,77 @@
+package progress
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestJSONSink(t *testing.T) {
+	var buf bytes.Buffer
+	sink := NewJSONSink(&buf)
+
+	emitTo(sink, Event{Type: EventStart, Op: "backup", Path: "WTF"})
+	emitTo(sink, Event{Type: EventWarning, Op: "backup", Message: "多行\n警告"})
+	emitTo(sink, Done("backup", Summary{Backup: "WTF_Backup", Files: 2, Bytes: 10}, errors.New("备份失败")))
+	emitTo(nil, Event{Type: EventError})
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("输出了 %d 行，期望每个事件一行:\n%s", len(lines), buf.String())
+	}
+	var events []Event
+	for _, line := range lines {
+		var e Event
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatalf("无法解析事件 %q: %v", line, err)
+		}
+		if e.Time.IsZero() {
+			t.Errorf("事件缺少时间: %s", line)
+		}
+		events = append(events, e)
+	}
+
+	if events[0].Type != EventStart || events[0].Op != "backup" || events[0].Path != "WTF" {
+		t.Errorf("第一个事件为 %+v", events[0])
+	}
+	if events[1].Message != "多行\n警告" {
+		t.Errorf("警告内容为 %q", events[1].Message)
+	}
+	done := events[2]
+	if done.Type != EventDone || done.Summary == nil || done.Summary.Files != 2 || done.Summary.Error != "备份失败" {
+		t.Errorf("结束事件为 %+v", done)
+	}
+}
+
+func TestProgressBarEvents(t *testing.T) {
+	var buf bytes.Buffer
+	pb := NewProgressBar(10, "备份", "")
+	pb.sink = NewJSONSink(&buf)
+	pb.SetFiles(1)
+	pb.Update(10)
+	pb.FileDone("a.lua")
+	pb.Finish()
+
+	var types []EventType
+	for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+		var e Event
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatalf("无法解析事件 %q: %v", line, err)
+		}
+		types = append(types, e.Type)
+		if e.Type == EventFile && (e.Path != "a.lua" || e.Files != 1 || e.TotalFiles != 1) {
+			t.Errorf("文件事件为 %+v", e)
+		}
+	}
+	want := []EventType{EventProgress, EventFile, EventProgress}
+	if len(types) != len(want) {
+		t.Fatalf("发布的事件为 %v，期望 %v", types, want)
+	}
+	for i := range want {
+		if types[i] != want[i] {
+			t.Fatalf("发布的事件为 %v，期望 %v", types, want)
+		}
+	}
+}
@@ -5,26 +5,32 @@ import (
 	"io"
 	"strings"
 	"sync"
+	"time"
 )
 
-// ProgressWriter 进度写入器
+// ProgressWriter 进度写入器，显示单个文件的复制进度
 type ProgressWriter struct {
 	io.Writer
-	total    int64
-	current  int64
-	lastPerc int
-	mu       sync.Mutex
-	prefix   string
-	suffix   string
+	total     int64
+	current   int64
+	lastPerc  int
+	lastWidth int
+	lastPrint time.Time
+	mu        sync.Mutex
+	prefix    string
+	suffix    string
+	out       terminal
 }
 
-// NewProgressWriter 创建新的进度写入器
+// NewProgressWriter 创建新的进度写入器，进度输出到 SetOutput 设置的输出目标
 func NewProgressWriter(w io.Writer, total int64, prefix, suffix string) *ProgressWriter {
 	return &ProgressWriter{
-		Writer: w,
-		total:  total,
-		prefix: prefix,
-		suffix: suffix,
+		Writer:    w,
+		total:     total,
+		lastPrint: time.Now(),
+		prefix:    prefix,
+		suffix:    suffix,
+		out:       newTerminal(),
 	}
 }
 
@@ -39,66 +45,146 @@ func (pw *ProgressWriter) Write(p []byte) (int, error) {
 	}
 
 	pw.current += int64(n)
-	perc := int(float64(pw.current) / float64(pw.total) * 100)
-	if perc != pw.lastPerc {
+	perc := pw.percent()
+	if perc == pw.lastPerc {
+		return n, nil
+	}
+	pw.lastPerc = perc
+	if pw.out.tty {
 		pw.updateProgress(perc)
-		pw.lastPerc = perc
+	} else if time.Since(pw.lastPrint) >= plainInterval {
+		// 输出被重定向时偶尔输出一行，避免日志中出现大量刷新进度的字符
+		pw.out.println(pw.render(perc))
+		pw.lastPrint = time.Now()
 	}
 	return n, nil
 }
 
+// Finish 结束进度显示：终端中换行，否则输出最终的进度
+func (pw *ProgressWriter) Finish() {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+
+	if pw.out.tty {
+		io.WriteString(pw.out.w, "\n")
+		return
+	}
+	pw.out.println(pw.render(pw.percent()))
+}
+
+// percent 已完成的百分比
+func (pw *ProgressWriter) percent() int {
+	if pw.total <= 0 {
+		return 100
+	}
+	return int(float64(pw.current) / float64(pw.total) * 100)
+}
+
 // updateProgress 更新进度显示
 func (pw *ProgressWriter) updateProgress(perc int) {
-	// 清除当前行
-	fmt.Print("\r" + strings.Repeat(" ", 80) + "\r")
+	pw.lastWidth = pw.out.redraw(pw.render(perc), pw.lastWidth)
+}
 
-	// 显示进度条
+// render 生成进度的文本
+func (pw *ProgressWriter) render(perc int) string {
 	barWidth := 30
 	completed := int(float64(barWidth) * float64(perc) / 100)
 	bar := strings.Repeat("=", completed) + strings.Repeat("-", barWidth-completed)
+	return fmt.Sprintf("%s [%s] %d%% %s", pw.prefix, bar, perc, pw.suffix)
+}
+
+// showDetail 是否在进度条上方逐行显示完成的文件
+var showDetail bool
 
-	// 构建进度信息
-	progress := fmt.Sprintf("%s [%s] %d%% %s", pw.prefix, bar, perc, pw.suffix)
-	fmt.Print("\r" + progress)
+// SetShowDetail 设置之后创建的进度条是否逐行显示完成的文件
+func SetShowDetail(detail bool) {
+	showDetail = detail
 }
 
-// ProgressBar 进度条
+// redrawInterval 进度条的最短重绘间隔，避免大量小文件时频繁刷新终端
+const redrawInterval = 100 * time.Millisecond
+
+// ProgressBar 整体进度条，可以被多个协程同时更新
+// 显示已完成的文件数和字节数、速度以及预计剩余时间。
+// 输出不是终端时每隔一段时间输出一行进度；
+// 设置了事件接收者时不输出进度，而是发布 progress 和 file 事件
 type ProgressBar struct {
-	total   int64
-	current int64
-	mu      sync.Mutex
-	prefix  string
-	suffix  string
+	total     int64
+	current   int64
+	files     int
+	filesDone int
+	detail    bool
+	sink      Sink
+	start     time.Time
+	lastDraw  time.Time
+	lastWidth int
+	mu        sync.Mutex
+	prefix    string
+	suffix    string
+	out       terminal
 }
 
-// NewProgressBar 创建新的进度条
+// NewProgressBar 创建新的进度条，total 为总字节数
 func NewProgressBar(total int64, prefix, suffix string) *ProgressBar {
 	return &ProgressBar{
 		total:  total,
+		detail: showDetail,
+		sink:   defaultSink,
+		start:  time.Now(),
 		prefix: prefix,
 		suffix: suffix,
+		out:    newTerminal(),
 	}
 }
 
-// Update 更新进度
+// SetFiles 设置文件总数，设置后进度中会显示已完成的文件数
+func (pb *ProgressBar) SetFiles(files int) {
+	pb.mu.Lock()
+	defer pb.mu.Unlock()
+	pb.files = files
+}
+
+// Update 增加已完成的字节数
 func (pb *ProgressBar) Update(n int64) {
 	pb.mu.Lock()
 	defer pb.mu.Unlock()
 
 	pb.current += n
-	perc := int(float64(pb.current) / float64(pb.total) * 100)
+	if time.Since(pb.lastDraw) >= pb.out.interval() {
+		pb.draw()
+	}
+}
 
-	// 清除当前行
-	fmt.Print("\r" + strings.Repeat(" ", 80) + "\r")
+// FileDone 完成一个文件，开启逐行显示时在进度条上方输出文件名
+func (pb *ProgressBar) FileDone(name string) {
+	pb.mu.Lock()
+	defer pb.mu.Unlock()
 
-	// 显示进度条
-	barWidth := 30
-	completed := int(float64(barWidth) * float64(perc) / 100)
-	bar := strings.Repeat("=", completed) + strings.Repeat("-", barWidth-completed)
+	pb.filesDone++
+	if pb.sink != nil {
+		emitTo(pb.sink, Event{Type: EventFile, Path: name, Bytes: pb.current, TotalBytes: pb.total, Files: pb.filesDone, TotalFiles: pb.files})
+		return
+	}
+	if pb.detail {
+		if !pb.out.tty {
+			pb.out.println("  " + name)
+			return
+		}
+		// 先清除进度条所在的行，再输出文件名，进度条重新绘制在下一行
+		pb.out.clear(pb.lastWidth)
+		pb.out.println("  " + name)
+		pb.lastWidth = 0
+		pb.draw()
+		return
+	}
+	if time.Since(pb.lastDraw) >= pb.out.interval() {
+		pb.draw()
+	}
+}
 
-	// 构建进度信息
-	progress := fmt.Sprintf("%s [%s] %d%% %s", pb.prefix, bar, perc, pb.suffix)
-	fmt.Print("\r" + progress)
+// Writer 返回一个写入器，写入 w 的字节数会计入进度
+func (pb *ProgressBar) Writer(w io.Writer) io.Writer {
+	return &barWriter{Writer: w, bar: pb}
 }
 
 // Finish 完成进度条
@@ -106,11 +192,127 @@ func (pb *ProgressBar) Finish() {
 	pb.mu.Lock()
 	defer pb.mu.Unlock()
 
-	// 清除当前行
-	fmt.Print("\r" + strings.Repeat(" ", 80) + "\r")
+	if pb.current < pb.total {
+		pb.current = pb.total
+	}
+	if pb.filesDone < pb.files {
+		pb.filesDone = pb.files
+	}
+	pb.draw()
+	if pb.sink == nil && pb.out.tty {
+		io.WriteString(pb.out.w, "\n")
+	}
+}
 
-	// 显示完成信息
-	bar := strings.Repeat("=", 30)
-	progress := fmt.Sprintf("%s [%s] 100%% %s", pb.prefix, bar, pb.suffix)
-	fmt.Print("\r" + progress + "\n")
+// Stop 停止进度条（例如出错或被取消时），保留当前的进度并换行
+func (pb *ProgressBar) Stop() {
+	pb.mu.Lock()
+	defer pb.mu.Unlock()
+
+	pb.draw()
+	if pb.sink == nil && pb.out.tty {
+		io.WriteString(pb.out.w, "\n")
+	}
+}
+
+// draw 重绘进度条，调用时需持有锁
+func (pb *ProgressBar) draw() {
+	if pb.sink != nil {
+		emitTo(pb.sink, Event{Type: EventProgress, Bytes: pb.current, TotalBytes: pb.total, Files: pb.filesDone, TotalFiles: pb.files})
+		pb.lastDraw = time.Now()
+		return
+	}
+
+	if pb.out.tty {
+		pb.lastWidth = pb.out.redraw(pb.render(), pb.lastWidth)
+	} else {
+		pb.out.println(pb.render())
+	}
+	pb.lastDraw = time.Now()
+}
+
+// render 生成进度条的文本
+func (pb *ProgressBar) render() string {
+	perc := 100
+	if pb.total > 0 {
+		perc = int(float64(pb.current) / float64(pb.total) * 100)
+		if perc > 100 {
+			perc = 100
+		}
+	}
+
+	barWidth := 30
+	completed := barWidth * perc / 100
+	bar := strings.Repeat("=", completed) + strings.Repeat("-", barWidth-completed)
+
+	parts := []string{fmt.Sprintf("%s [%s] %3d%%", pb.prefix, bar, perc)}
+	if pb.files > 0 {
+		parts = append(parts, fmt.Sprintf("%d/%d 个文件", pb.filesDone, pb.files))
+	}
+	parts = append(parts, FormatBytes(pb.current)+"/"+FormatBytes(pb.total))
+
+	// 刚开始时速度还不准确，半秒后再显示速度和剩余时间
+	if elapsed := time.Since(pb.start); elapsed >= 500*time.Millisecond && pb.current > 0 {
+		rate := float64(pb.current) / elapsed.Seconds()
+		parts = append(parts, FormatBytes(int64(rate))+"/s")
+		if pb.current < pb.total {
+			remaining := time.Duration(float64(pb.total-pb.current) / rate * float64(time.Second))
+			parts = append(parts, "剩余 "+FormatDuration(remaining))
+		}
+	}
+	if pb.suffix != "" {
+		parts = append(parts, pb.suffix)
+	}
+	return strings.Join(parts, " ")
+}
+
+// barWriter 将写入的字节数计入进度条
+type barWriter struct {
+	io.Writer
+	bar *ProgressBar
+}
+
+func (w *barWriter) Write(p []byte) (int, error) {
+	n, err := w.Writer.Write(p)
+	w.bar.Update(int64(n))
+	return n, err
+}
+
+// displayWidth 估算文本在终端中的显示宽度，中文等宽字符占两列
+func displayWidth(s string) int {
+	width := 0
+	for _, r := range s {
+		if r >= 0x1100 {
+			width += 2
+		} else {
+			width++
+		}
+	}
+	return width
+}
+
+// FormatDuration 将时长格式化为 mm:ss 或 hh:mm:ss
+func FormatDuration(d time.Duration) string {
+	d = d.Round(time.Second)
+	h := int(d / time.Hour)
+	m := int(d % time.Hour / time.Minute)
+	sec := int(d % time.Minute / time.Second)
+	if h > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", h, m, sec)
+	}
+	return fmt.Sprintf("%02d:%02d", m, sec)
+}
+
+// FormatBytes 将字节数格式化为易读的形式，例如 1.5 MB
+func FormatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
 }
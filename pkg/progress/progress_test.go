@@ -0,0 +1,127 @@
+package progress
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+// captureOutput 将之后创建的进度条输出到缓冲区，测试结束后恢复
+func captureOutput(t *testing.T, detail bool) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	SetOutput(&buf)
+	SetShowDetail(detail)
+	t.Cleanup(func() {
+		SetOutput(os.Stdout)
+		SetShowDetail(false)
+	})
+	return &buf
+}
+
+func TestProgressBarPlainOutput(t *testing.T) {
+	buf := captureOutput(t, false)
+
+	pb := NewProgressBar(2048, "备份", "WTF_Backup")
+	pb.SetFiles(2)
+	pb.Update(1024)
+	// 输出不是终端时两次输出之间至少间隔 plainInterval
+	pb.FileDone("Account/ACC/SavedVariables/A.lua")
+	pb.Update(1024)
+	pb.FileDone("Account/ACC/SavedVariables/B.lua")
+	pb.Finish()
+
+	out := buf.String()
+	if strings.Contains(out, "\r") {
+		t.Errorf("输出不是终端时不应使用 \\r 刷新: %q", out)
+	}
+	lines := strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+	want := []string{
+		"备份 [===============---------------]  50% 0/2 个文件 1.0 KB/2.0 KB WTF_Backup",
+		"备份 [==============================] 100% 2/2 个文件 2.0 KB/2.0 KB WTF_Backup",
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("输出了 %d 行，期望 %d 行:\n%s", len(lines), len(want), out)
+	}
+	for i := range want {
+		if lines[i] != want[i] {
+			t.Errorf("第 %d 行为 %q，期望 %q", i+1, lines[i], want[i])
+		}
+	}
+}
+
+func TestProgressBarDetailPlain(t *testing.T) {
+	buf := captureOutput(t, true)
+
+	pb := NewProgressBar(10, "恢复", "")
+	pb.SetFiles(2)
+	pb.FileDone("a.lua")
+	pb.FileDone("b.lua")
+
+	if out := buf.String(); out != "  a.lua\n  b.lua\n" {
+		t.Errorf("逐行显示的输出为 %q", out)
+	}
+}
+
+func TestProgressBarDetailTerminal(t *testing.T) {
+	var buf bytes.Buffer
+	pb := NewProgressBar(10, "恢复", "很长的后缀文字")
+	pb.SetFiles(1)
+	pb.detail = true
+	pb.out = terminal{w: &buf, tty: true, width: 40}
+
+	pb.Update(5)
+	pb.FileDone("a.lua")
+	pb.Finish()
+
+	out := buf.String()
+	if !strings.Contains(out, "\r  a.lua\n") {
+		t.Errorf("文件名应在清除进度条后单独输出一行: %q", out)
+	}
+	if !strings.HasSuffix(out, "\n") {
+		t.Errorf("完成后应换行: %q", out)
+	}
+	// 每次重绘都不能超过终端宽度，否则折行后无法覆盖
+	for _, line := range strings.FieldsFunc(out, func(r rune) bool { return r == '\r' || r == '\n' }) {
+		if w := displayWidth(line); w > 39 {
+			t.Errorf("输出宽度 %d 超过终端宽度: %q", w, line)
+		}
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		s     string
+		width int
+		want  string
+	}{
+		{"abc", 5, "abc"},
+		{"abcdef", 3, "abc"},
+		{"中文字符", 5, "中文"},
+		{"中文字符", 4, "中文"},
+		{"a中文", 2, "a"},
+		{"abc", 0, "abc"},
+	}
+	for _, tt := range tests {
+		if got := truncate(tt.s, tt.width); got != tt.want {
+			t.Errorf("truncate(%q, %d) = %q，期望 %q", tt.s, tt.width, got, tt.want)
+		}
+	}
+}
+
+func TestRedraw(t *testing.T) {
+	var buf bytes.Buffer
+	term := terminal{w: &buf, tty: true, width: 10}
+
+	width := term.redraw("进度 [==] 100%", 0)
+	if width != 9 || buf.String() != "\r进度 [==]" {
+		t.Errorf("redraw 输出 %q，宽度 %d", buf.String(), width)
+	}
+
+	buf.Reset()
+	width = term.redraw("ab", width)
+	if width != 2 || buf.String() != "\rab"+strings.Repeat(" ", 7) {
+		t.Errorf("较短的进度应覆盖上一次的输出: %q，宽度 %d", buf.String(), width)
+	}
+}
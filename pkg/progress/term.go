@@ -0,0 +1,117 @@
+package progress
+
+import (
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultWidth 无法获取终端宽度时使用的宽度
+	defaultWidth = 80
+	// plainInterval 输出不是终端时两次输出进度的最短间隔
+	plainInterval = 10 * time.Second
+)
+
+var (
+	outputMu sync.Mutex
+	// 全局默认的进度输出目标
+	output io.Writer = os.Stdout
+)
+
+// SetOutput 设置之后创建的进度条和进度写入器的输出目标
+func SetOutput(w io.Writer) {
+	outputMu.Lock()
+	defer outputMu.Unlock()
+	output = w
+}
+
+// terminal 进度的输出目标
+type terminal struct {
+	w io.Writer
+	// 是否为终端：终端中用 \r 在同一行刷新进度，否则偶尔输出一整行
+	tty bool
+	// 终端宽度（列数）
+	width int
+}
+
+// newTerminal 检查默认的输出目标是否为终端并获取终端宽度
+func newTerminal() terminal {
+	outputMu.Lock()
+	w := output
+	outputMu.Unlock()
+
+	t := terminal{w: w, width: defaultWidth}
+	f, ok := w.(*os.File)
+	if !ok {
+		return t
+	}
+	width, ok := terminalWidth(f)
+	if !ok {
+		return t
+	}
+	t.tty = true
+	if width <= 0 {
+		// 部分终端无法查询宽度，使用 shell 设置的 COLUMNS
+		width, _ = strconv.Atoi(os.Getenv("COLUMNS"))
+	}
+	if width > 0 {
+		t.width = width
+	}
+	return t
+}
+
+// interval 两次刷新进度的最短间隔
+func (t terminal) interval() time.Duration {
+	if t.tty {
+		return redrawInterval
+	}
+	return plainInterval
+}
+
+// redraw 在终端中用 line 覆盖上一次输出的进度，返回这次输出的显示宽度。
+// 超出终端宽度的部分会被截断，避免折行后无法覆盖
+func (t terminal) redraw(line string, lastWidth int) int {
+	line = truncate(line, t.width-1)
+	width := displayWidth(line)
+
+	// 用空格覆盖上一次输出中更长的部分
+	padding := ""
+	if width < lastWidth {
+		padding = strings.Repeat(" ", lastWidth-width)
+	}
+	io.WriteString(t.w, "\r"+line+padding)
+	return width
+}
+
+// clear 清除终端中上一次输出的进度
+func (t terminal) clear(lastWidth int) {
+	io.WriteString(t.w, "\r"+strings.Repeat(" ", lastWidth)+"\r")
+}
+
+// println 输出一整行
+func (t terminal) println(line string) {
+	io.WriteString(t.w, line+"\n")
+}
+
+// truncate 按显示宽度截断文本
+func truncate(s string, width int) string {
+	if width <= 0 || displayWidth(s) <= width {
+		return s
+	}
+	used := 0
+	for i, r := range s {
+		w := 1
+		if r >= 0x1100 {
+			w = 2
+		}
+		if used+w > width {
+			return s[:i]
+		}
+		used += w
+	}
+	return s
+}
@@ -0,0 +1,14 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly || windows)
+
+package progress
+
+import "os"
+
+// terminalWidth 无法查询终端宽度的系统上只判断 f 是否为字符设备
+func terminalWidth(f *os.File) (width int, ok bool) {
+	info, err := f.Stat()
+	if err != nil {
+		return 0, false
+	}
+	return 0, info.Mode()&os.ModeCharDevice != 0
+}
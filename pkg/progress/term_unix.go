@@ -0,0 +1,25 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+
+package progress
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// winsize ioctl TIOCGWINSZ 返回的终端大小
+type winsize struct {
+	Row, Col       uint16
+	Xpixel, Ypixel uint16
+}
+
+// terminalWidth 返回终端的列数，f 不是终端时 ok 为 false
+func terminalWidth(f *os.File) (width int, ok bool) {
+	var ws winsize
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), uintptr(syscall.TIOCGWINSZ), uintptr(unsafe.Pointer(&ws)))
+	if errno != 0 {
+		return 0, false
+	}
+	return int(ws.Col), true
+}
@@ -0,0 +1,30 @@
+//go:build windows
+
+package progress
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+var procGetConsoleScreenBufferInfo = syscall.NewLazyDLL("kernel32.dll").NewProc("GetConsoleScreenBufferInfo")
+
+// consoleScreenBufferInfo 对应 Windows 的 CONSOLE_SCREEN_BUFFER_INFO
+type consoleScreenBufferInfo struct {
+	Size              struct{ X, Y int16 }
+	CursorPosition    struct{ X, Y int16 }
+	Attributes        uint16
+	Window            struct{ Left, Top, Right, Bottom int16 }
+	MaximumWindowSize struct{ X, Y int16 }
+}
+
+// terminalWidth 返回控制台窗口的列数，f 不是控制台时 ok 为 false
+func terminalWidth(f *os.File) (width int, ok bool) {
+	var info consoleScreenBufferInfo
+	r, _, _ := procGetConsoleScreenBufferInfo.Call(f.Fd(), uintptr(unsafe.Pointer(&info)))
+	if r == 0 {
+		return 0, false
+	}
+	return int(info.Window.Right-info.Window.Left) + 1, true
+}
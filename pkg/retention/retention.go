@@ -0,0 +1,226 @@
+package retention
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lizhening/WtfBackup/pkg/logger"
+	"github.com/lizhening/WtfBackup/pkg/snapshot"
+)
+
+// Policy 备份保留策略（祖父-父-子轮换）
+// 各条规则取并集：只要符合任意一条规则，备份就会被保留
+type Policy struct {
+	// 保留最新的 N 个备份
+	Last int `yaml:"last,omitempty"`
+	// 保留最近 N 小时内的所有备份
+	Hours int `yaml:"hours,omitempty"`
+	// 每天保留一个备份（当天最新的），共保留最近 N 个有备份的日期
+	Daily int `yaml:"daily,omitempty"`
+	// 每周保留一个备份，共 N 周
+	Weekly int `yaml:"weekly,omitempty"`
+	// 每月保留一个备份，共 N 个月
+	Monthly int `yaml:"monthly,omitempty"`
+}
+
+// IsZero 是否没有设置任何保留规则
+func (p Policy) IsZero() bool {
+	return p == Policy{}
+}
+
+// Validate 检查保留规则是否有效
+func (p Policy) Validate() error {
+	if p.Last < 0 || p.Hours < 0 || p.Daily < 0 || p.Weekly < 0 || p.Monthly < 0 {
+		return fmt.Errorf("保留规则不能为负数")
+	}
+	return nil
+}
+
+// Parse 解析 hours=24,daily=7,weekly=4,monthly=6 形式的保留策略，
+// 可用的规则为 last、hours、daily、weekly、monthly，空字符串表示不设置任何规则
+func Parse(s string) (Policy, error) {
+	var p Policy
+	s = strings.TrimSpace(s)
+	if s == "" || s == "none" {
+		return p, nil
+	}
+
+	fields := map[string]*int{
+		"last":    &p.Last,
+		"hours":   &p.Hours,
+		"daily":   &p.Daily,
+		"weekly":  &p.Weekly,
+		"monthly": &p.Monthly,
+	}
+	for _, part := range strings.Split(s, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return Policy{}, fmt.Errorf("无效的保留规则 %q，请使用 <规则>=<数量> 格式", part)
+		}
+		field, ok := fields[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			return Policy{}, fmt.Errorf("未知的保留规则 %q (可选: last, hours, daily, weekly, monthly)", name)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			return Policy{}, fmt.Errorf("无效的保留数量 %q: %w", value, err)
+		}
+		*field = n
+	}
+	return p, p.Validate()
+}
+
+// String 返回保留策略的说明
+func (p Policy) String() string {
+	var parts []string
+	if p.Last > 0 {
+		parts = append(parts, fmt.Sprintf("最新 %d 个", p.Last))
+	}
+	if p.Hours > 0 {
+		parts = append(parts, fmt.Sprintf("最近 %d 小时内的全部", p.Hours))
+	}
+	if p.Daily > 0 {
+		parts = append(parts, fmt.Sprintf("每天 1 个共 %d 天", p.Daily))
+	}
+	if p.Weekly > 0 {
+		parts = append(parts, fmt.Sprintf("每周 1 个共 %d 周", p.Weekly))
+	}
+	if p.Monthly > 0 {
+		parts = append(parts, fmt.Sprintf("每月 1 个共 %d 个月", p.Monthly))
+	}
+	if len(parts) == 0 {
+		return "保留全部"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Decision 对一个备份的处理结果
+type Decision struct {
+	Backup snapshot.Backup
+	// 备份的附加信息，读取失败时为 nil
+	Meta *snapshot.Meta
+	// 是否保留
+	Keep bool
+	// 保留或删除的原因
+	Reason string
+}
+
+// bucket 按时间分组的规则，每组保留最新的一个备份
+type bucket struct {
+	name  string
+	count int
+	key   func(t time.Time) string
+}
+
+// Apply 按保留策略决定每个备份是否保留，backups 需按时间倒序排列（与 snapshot.List 相同）
+//
+// 被标记为可疑的备份（例如 SavedVariables 损坏）不计入任何规则，
+// 这样损坏的备份不会把完好的备份挤出保留范围。
+// 可疑的备份只要比最旧的保留备份新就会一直保留，没有保留任何完好的备份时也全部保留。
+// 已固定的备份和无法读取备份信息的备份总是保留，同样不计入任何规则。
+// 没有设置任何规则时保留所有备份
+func Apply(backups []snapshot.Backup, policy Policy, now time.Time) []Decision {
+	decisions := make([]Decision, len(backups))
+	if policy.IsZero() {
+		for i, b := range backups {
+			decisions[i] = Decision{Backup: b, Keep: true, Reason: "没有设置保留规则"}
+		}
+		return decisions
+	}
+
+	buckets := []*bucket{
+		{name: "每日备份", count: policy.Daily, key: func(t time.Time) string { return t.Format("2006-01-02") }},
+		{name: "每周备份", count: policy.Weekly, key: func(t time.Time) string {
+			year, week := t.ISOWeek()
+			return fmt.Sprintf("%d-W%02d", year, week)
+		}},
+		{name: "每月备份", count: policy.Monthly, key: func(t time.Time) string { return t.Format("2006-01") }},
+	}
+	lastKeys := make([]string, len(buckets))
+	since := now.Add(-time.Duration(policy.Hours) * time.Hour)
+
+	kept := 0
+	oldestKept := -1
+	for i, b := range backups {
+		decisions[i].Backup = b
+
+		meta, err := snapshot.LoadMeta(b)
+		if err != nil {
+			// 无法确定备份是否已固定，宁可保留也不误删
+			logger.Warn("读取备份信息失败 %s: %v", b.Path, err)
+			decisions[i].Keep = true
+			decisions[i].Reason = "无法读取备份信息"
+			continue
+		}
+		decisions[i].Meta = meta
+		if meta.Pinned {
+			decisions[i].Keep = true
+			decisions[i].Reason = "已固定"
+			if meta.Note != "" {
+				decisions[i].Reason += ": " + meta.Note
+			}
+			continue
+		}
+		if meta.Suspect {
+			continue
+		}
+
+		var reasons []string
+		if kept < policy.Last {
+			reasons = append(reasons, fmt.Sprintf("最新的 %d 个备份之一", policy.Last))
+		}
+		kept++
+		if policy.Hours > 0 && !b.Time.Before(since) {
+			reasons = append(reasons, fmt.Sprintf("最近 %d 小时内", policy.Hours))
+		}
+		for j, bk := range buckets {
+			key := bk.key(b.Time)
+			if bk.count <= 0 || key == lastKeys[j] {
+				continue
+			}
+			lastKeys[j] = key
+			bk.count--
+			reasons = append(reasons, bk.name+" "+key)
+		}
+
+		if len(reasons) > 0 {
+			decisions[i].Keep = true
+			decisions[i].Reason = strings.Join(reasons, ", ")
+			oldestKept = i
+		} else {
+			decisions[i].Reason = "不符合任何保留规则"
+		}
+	}
+
+	// 可疑的备份
+	for i := range decisions {
+		if decisions[i].Reason != "" {
+			continue
+		}
+		switch {
+		case oldestKept < 0:
+			// 没有可以保留的完好备份时不删除可疑的备份，以免删光所有备份
+			decisions[i].Keep = true
+			decisions[i].Reason = "可疑的备份，没有其他可以保留的备份"
+		case i < oldestKept:
+			decisions[i].Keep = true
+			decisions[i].Reason = "可疑的备份，比最旧的保留备份新"
+		default:
+			decisions[i].Reason = "可疑的备份，比所有保留的备份都旧"
+		}
+	}
+	return decisions
+}
+
+// Expired 返回按保留策略将被删除的备份
+func Expired(backups []snapshot.Backup, policy Policy, now time.Time) []Decision {
+	var expired []Decision
+	for _, d := range Apply(backups, policy, now) {
+		if !d.Keep {
+			expired = append(expired, d)
+		}
+	}
+	return expired
+}
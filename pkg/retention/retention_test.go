@@ -0,0 +1,173 @@
+package retention
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lizhening/WtfBackup/pkg/snapshot"
+)
+
+// makeBackups 在临时目录中为每个时间创建一个文件夹格式的备份，返回按时间倒序排列的备份
+func makeBackups(t *testing.T, times ...time.Time) []snapshot.Backup {
+	t.Helper()
+	dir := t.TempDir()
+	backups := make([]snapshot.Backup, len(times))
+	for i, tm := range times {
+		name := snapshot.NewName(tm)
+		path := filepath.Join(dir, name)
+		if err := os.Mkdir(path, 0755); err != nil {
+			t.Fatal(err)
+		}
+		backups[i] = snapshot.Backup{Name: name, Path: path, Format: snapshot.FormatDir, Time: tm}
+	}
+	return backups
+}
+
+func TestApplyKeepsBackupWithUnreadableMeta(t *testing.T) {
+	now := time.Date(2024, 5, 10, 12, 0, 0, 0, time.Local)
+	backups := makeBackups(t, now.Add(-time.Hour), now.Add(-48*time.Hour))
+	// 第二个备份的信息文件损坏，无法确定它是否已固定
+	if err := os.WriteFile(backups[1].MetaPath(), []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	decisions := Apply(backups, Policy{Last: 1}, now)
+	if !decisions[0].Keep {
+		t.Errorf("最新的备份应保留: %s", decisions[0].Reason)
+	}
+	if !decisions[1].Keep || decisions[1].Reason != "无法读取备份信息" {
+		t.Errorf("无法读取备份信息的备份应保留，得到 Keep=%v Reason=%q", decisions[1].Keep, decisions[1].Reason)
+	}
+}
+
+// setMeta 保存备份的附加信息
+func setMeta(t *testing.T, b snapshot.Backup, meta snapshot.Meta) {
+	t.Helper()
+	if err := snapshot.SaveMeta(b, &meta); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// kept 返回每个备份是否保留
+func kept(decisions []Decision) []bool {
+	keep := make([]bool, len(decisions))
+	for i, d := range decisions {
+		keep[i] = d.Keep
+	}
+	return keep
+}
+
+func TestApply(t *testing.T) {
+	now := time.Date(2024, 5, 10, 12, 0, 0, 0, time.Local)
+	times := []time.Time{
+		now.Add(-1 * time.Hour),  // 5-10 11:00
+		now.Add(-2 * time.Hour),  // 5-10 10:00
+		now.Add(-26 * time.Hour), // 5-09 10:00
+		now.Add(-50 * time.Hour), // 5-08 10:00
+		now.AddDate(0, -1, 0),    // 4-10
+	}
+
+	tests := []struct {
+		name   string
+		policy Policy
+		want   []bool
+	}{
+		{"没有规则时全部保留", Policy{}, []bool{true, true, true, true, true}},
+		{"最新的 N 个", Policy{Last: 2}, []bool{true, true, false, false, false}},
+		{"最近 N 小时", Policy{Hours: 30}, []bool{true, true, true, false, false}},
+		{"每天保留最新的一个", Policy{Daily: 2}, []bool{true, false, true, false, false}},
+		{"每月保留一个", Policy{Monthly: 2}, []bool{true, false, false, false, true}},
+		{"规则取并集", Policy{Last: 1, Daily: 3}, []bool{true, false, true, true, false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backups := makeBackups(t, times...)
+			decisions := Apply(backups, tt.policy, now)
+			if got := kept(decisions); !equalBools(got, tt.want) {
+				t.Errorf("保留 %v，期望 %v", got, tt.want)
+			}
+			for _, d := range decisions {
+				if d.Reason == "" {
+					t.Errorf("%s 缺少原因", d.Backup.Name)
+				}
+			}
+		})
+	}
+}
+
+func TestApplyPinned(t *testing.T) {
+	now := time.Date(2024, 5, 10, 12, 0, 0, 0, time.Local)
+	backups := makeBackups(t, now.Add(-time.Hour), now.Add(-2*time.Hour), now.Add(-3*time.Hour))
+	setMeta(t, backups[0], snapshot.Meta{Pinned: true, Note: "升级前"})
+	setMeta(t, backups[2], snapshot.Meta{Pinned: true})
+
+	decisions := Apply(backups, Policy{Last: 1}, now)
+	// 已固定的备份不占用 last=1 的名额
+	if got, want := kept(decisions), []bool{true, true, true}; !equalBools(got, want) {
+		t.Errorf("保留 %v，期望 %v", got, want)
+	}
+	if decisions[0].Reason != "已固定: 升级前" {
+		t.Errorf("原因为 %q", decisions[0].Reason)
+	}
+}
+
+func TestApplySuspect(t *testing.T) {
+	now := time.Date(2024, 5, 10, 12, 0, 0, 0, time.Local)
+	times := []time.Time{now.Add(-time.Hour), now.Add(-2 * time.Hour), now.Add(-3 * time.Hour), now.Add(-4 * time.Hour)}
+
+	t.Run("比最旧的保留备份新", func(t *testing.T) {
+		backups := makeBackups(t, times...)
+		setMeta(t, backups[0], snapshot.Meta{Suspect: true})
+		setMeta(t, backups[3], snapshot.Meta{Suspect: true})
+		decisions := Apply(backups, Policy{Last: 2}, now)
+		// 可疑的备份不计入 last=2，因此保留第 2、3 个完好的备份
+		if got, want := kept(decisions), []bool{true, true, true, false}; !equalBools(got, want) {
+			t.Errorf("保留 %v，期望 %v", got, want)
+		}
+	})
+
+	t.Run("没有完好的备份", func(t *testing.T) {
+		backups := makeBackups(t, times...)
+		for _, b := range backups {
+			setMeta(t, b, snapshot.Meta{Suspect: true})
+		}
+		decisions := Apply(backups, Policy{Last: 1}, now)
+		if got, want := kept(decisions), []bool{true, true, true, true}; !equalBools(got, want) {
+			t.Errorf("保留 %v，期望全部保留", got)
+		}
+	})
+}
+
+func TestParse(t *testing.T) {
+	p, err := Parse("hours=24, daily=7,weekly=4,monthly=6,last=3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Policy{Last: 3, Hours: 24, Daily: 7, Weekly: 4, Monthly: 6}); p != want {
+		t.Errorf("Parse 返回 %+v，期望 %+v", p, want)
+	}
+	for _, s := range []string{"", "none"} {
+		if p, err := Parse(s); err != nil || !p.IsZero() {
+			t.Errorf("Parse(%q) = %+v, %v，期望没有规则", s, p, err)
+		}
+	}
+	for _, s := range []string{"daily", "yearly=1", "daily=x", "daily=-1"} {
+		if _, err := Parse(s); err == nil {
+			t.Errorf("Parse(%q) 应失败", s)
+		}
+	}
+}
+
+func equalBools(a, b []bool) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,213 @@
+package snapshot
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/lizhening/WtfBackup/pkg/progress"
+)
+
+// archiveWriter 压缩包写入器，按遍历顺序逐个写入条目
+type archiveWriter interface {
+	// WriteDir 写入文件夹条目
+	WriteDir(name string, info os.FileInfo) error
+	// WriteFile 写入文件条目
+	WriteFile(name string, info os.FileInfo, r io.Reader) error
+	// Close 刷新并关闭压缩包（不关闭底层文件）
+	Close() error
+}
+
+// CreateArchive 将 src 文件夹以流的方式写入单个压缩包文件 dst，ctx 被取消时停止写入
+func CreateArchive(ctx context.Context, src, dst string, format Format, showProgress bool) error {
+	if !format.IsArchive() {
+		return fmt.Errorf("%s 不是压缩包格式", format)
+	}
+
+	var bar *progress.ProgressBar
+	if showProgress {
+		total, files, err := dirSize(src)
+		if err != nil {
+			return fmt.Errorf("计算文件夹大小失败: %w", err)
+		}
+		bar = progress.NewProgressBar(total, "压缩备份", filepath.Base(dst))
+		bar.SetFiles(files)
+	}
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("创建压缩包失败: %w", err)
+	}
+	defer out.Close()
+
+	aw, err := newArchiveWriter(out, format)
+	if err != nil {
+		return err
+	}
+
+	err = filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		relPath, err := filepath.Rel(src, path)
+		if err != nil {
+			return fmt.Errorf("计算相对路径失败: %w", err)
+		}
+		if relPath == "." {
+			return nil
+		}
+		name := filepath.ToSlash(relPath)
+
+		if info.IsDir() {
+			return aw.WriteDir(name, info)
+		}
+		if !info.Mode().IsRegular() {
+			// 跳过符号链接等特殊文件
+			return nil
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("打开源文件失败: %w", err)
+		}
+		defer f.Close()
+
+		if err := aw.WriteFile(name, info, f); err != nil {
+			return fmt.Errorf("写入 %s 失败: %w", name, err)
+		}
+		if bar != nil {
+			bar.Update(info.Size())
+			bar.FileDone(name)
+		}
+		return nil
+	})
+	if err != nil {
+		if bar != nil {
+			bar.Stop()
+		}
+		aw.Close()
+		return fmt.Errorf("写入压缩包失败: %w", err)
+	}
+
+	if err := aw.Close(); err != nil {
+		return fmt.Errorf("关闭压缩包失败: %w", err)
+	}
+	if bar != nil {
+		bar.Finish()
+	}
+	return out.Close()
+}
+
+// newArchiveWriter 根据格式创建压缩包写入器
+func newArchiveWriter(w io.Writer, format Format) (archiveWriter, error) {
+	switch format {
+	case FormatZip:
+		return &zipWriter{zw: zip.NewWriter(w)}, nil
+	case FormatTarGz:
+		gz := gzip.NewWriter(w)
+		return &tarWriter{tw: tar.NewWriter(gz), compressor: gz}, nil
+	case FormatTarZst:
+		zw, err := zstd.NewWriter(w)
+		if err != nil {
+			return nil, fmt.Errorf("创建zstd压缩器失败: %w", err)
+		}
+		return &tarWriter{tw: tar.NewWriter(zw), compressor: zw}, nil
+	default:
+		return nil, fmt.Errorf("不支持的压缩包格式: %s", format)
+	}
+}
+
+// zipWriter zip压缩包写入器
+type zipWriter struct {
+	zw *zip.Writer
+}
+
+func (w *zipWriter) WriteDir(name string, info os.FileInfo) error {
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return err
+	}
+	header.Name = name + "/"
+	_, err = w.zw.CreateHeader(header)
+	return err
+}
+
+func (w *zipWriter) WriteFile(name string, info os.FileInfo, r io.Reader) error {
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return err
+	}
+	header.Name = name
+	header.Method = zip.Deflate
+	fw, err := w.zw.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(fw, r)
+	return err
+}
+
+func (w *zipWriter) Close() error {
+	return w.zw.Close()
+}
+
+// tarWriter tar压缩包写入器，外层包一个压缩流
+type tarWriter struct {
+	tw         *tar.Writer
+	compressor io.WriteCloser
+}
+
+func (w *tarWriter) WriteDir(name string, info os.FileInfo) error {
+	header, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return err
+	}
+	header.Name = name + "/"
+	return w.tw.WriteHeader(header)
+}
+
+func (w *tarWriter) WriteFile(name string, info os.FileInfo, r io.Reader) error {
+	header, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return err
+	}
+	header.Name = name
+	if err := w.tw.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err = io.Copy(w.tw, r)
+	return err
+}
+
+func (w *tarWriter) Close() error {
+	if err := w.tw.Close(); err != nil {
+		w.compressor.Close()
+		return err
+	}
+	return w.compressor.Close()
+}
+
+// dirSize 计算文件夹中所有普通文件的总大小和数量
+func dirSize(root string) (size int64, files int, err error) {
+	err = filepath.Walk(root, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			size += info.Size()
+			files++
+		}
+		return nil
+	})
+	return size, files, err
+}
@@ -0,0 +1,108 @@
+package snapshot
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Format 备份格式
+type Format string
+
+const (
+	// FormatDir 未压缩的文件夹
+	FormatDir Format = "dir"
+	// FormatZip zip压缩包
+	FormatZip Format = "zip"
+	// FormatTarGz gzip压缩的tar包
+	FormatTarGz Format = "tar.gz"
+	// FormatTarZst zstd压缩的tar包
+	FormatTarZst Format = "tar.zst"
+	// FormatRepo 去重仓库：文件内容按哈希存入对象存储，备份本身只是一份清单
+	FormatRepo Format = "repo"
+)
+
+// Formats 所有支持的备份格式
+var Formats = []Format{FormatDir, FormatZip, FormatTarGz, FormatTarZst, FormatRepo}
+
+const (
+	// NamePrefix 备份名称前缀
+	NamePrefix = "WTF_Backup_"
+	// TimeLayout 备份名称中的时间戳格式
+	TimeLayout = "2006-01-02_15-04-05"
+)
+
+// ParseFormat 解析备份格式，空字符串视为文件夹格式
+func ParseFormat(s string) (Format, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" {
+		return FormatDir, nil
+	}
+	for _, f := range Formats {
+		if string(f) == s {
+			return f, nil
+		}
+	}
+	return "", fmt.Errorf("不支持的备份格式: %s (可选: %s)", s, formatList())
+}
+
+// Ext 返回备份格式对应的文件扩展名，文件夹格式没有扩展名
+func (f Format) Ext() string {
+	switch f {
+	case FormatDir:
+		return ""
+	case FormatRepo:
+		return ".snapshot"
+	default:
+		return "." + string(f)
+	}
+}
+
+// IsArchive 是否为单文件压缩包格式
+func (f Format) IsArchive() bool {
+	return f == FormatZip || f == FormatTarGz || f == FormatTarZst
+}
+
+// NewName 根据时间生成备份名称，格式为 WTF_Backup_YYYY-MM-DD_HH-MM-SS
+func NewName(t time.Time) string {
+	return NamePrefix + t.Format(TimeLayout)
+}
+
+// ParseName 从备份文件名中解析出备份名称、格式和时间
+// isDir 表示该条目是否为文件夹，文件夹只会被识别为 FormatDir
+func ParseName(fileName string, isDir bool) (name string, format Format, t time.Time, ok bool) {
+	if !strings.HasPrefix(fileName, NamePrefix) {
+		return "", "", time.Time{}, false
+	}
+
+	name = fileName
+	format = FormatDir
+	if !isDir {
+		format = ""
+		for _, f := range Formats {
+			if f != FormatDir && strings.HasSuffix(fileName, f.Ext()) {
+				name = strings.TrimSuffix(fileName, f.Ext())
+				format = f
+				break
+			}
+		}
+		if format == "" {
+			return "", "", time.Time{}, false
+		}
+	}
+
+	t, err := time.ParseInLocation(TimeLayout, strings.TrimPrefix(name, NamePrefix), time.Local)
+	if err != nil {
+		return "", "", time.Time{}, false
+	}
+	return name, format, t, true
+}
+
+// formatList 返回以逗号分隔的格式列表
+func formatList() string {
+	names := make([]string, len(Formats))
+	for i, f := range Formats {
+		names[i] = string(f)
+	}
+	return strings.Join(names, ", ")
+}
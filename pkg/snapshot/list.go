@@ -0,0 +1,151 @@
+package snapshot
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Backup 备份目录中的一个备份
+type Backup struct {
+	// 备份名称，例如 WTF_Backup_2023-04-23_15-30-45
+	Name string
+	// 备份文件或文件夹的完整路径
+	Path string
+	// 备份格式
+	Format Format
+	// 备份时间
+	Time time.Time
+}
+
+// List 列出备份目录中的所有备份，按时间倒序排列（最新的备份在最前面）
+func List(backupDir string) ([]Backup, error) {
+	entries, err := os.ReadDir(backupDir)
+	if err != nil {
+		return nil, fmt.Errorf("读取备份目录失败: %w", err)
+	}
+
+	var backups []Backup
+	for _, entry := range entries {
+		name, format, t, ok := ParseName(entry.Name(), entry.IsDir())
+		if !ok {
+			continue
+		}
+		backups = append(backups, Backup{
+			Name:   name,
+			Path:   filepath.Join(backupDir, entry.Name()),
+			Format: format,
+			Time:   t,
+		})
+	}
+
+	sort.SliceStable(backups, func(i, j int) bool {
+		if !backups[i].Time.Equal(backups[j].Time) {
+			return backups[i].Time.After(backups[j].Time)
+		}
+		return backups[i].Name > backups[j].Name
+	})
+	return backups, nil
+}
+
+// StagingSuffix 正在写入的备份使用的后缀，备份完成并校验通过后才会重命名为正式名称
+const StagingSuffix = ".inprogress"
+
+// Staging 返回写入过程中使用的临时备份，与正式备份位于同一目录以便原子重命名
+func (b Backup) Staging() Backup {
+	staging := b
+	staging.Path = b.Path + StagingSuffix
+	return staging
+}
+
+// CleanStaging 删除上次未完成（例如被中断）的备份留下的临时文件，返回被删除的路径
+// 持有锁文件的临时备份可能正由其他程序写入，锁文件过期之前不会删除
+func CleanStaging(backupDir string) ([]string, error) {
+	entries, err := os.ReadDir(backupDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("读取备份目录失败: %w", err)
+	}
+
+	var removed []string
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), StagingSuffix) {
+			continue
+		}
+		name, _, _, ok := ParseName(strings.TrimSuffix(entry.Name(), StagingSuffix), entry.IsDir())
+		if !ok {
+			continue
+		}
+
+		path := filepath.Join(backupDir, entry.Name())
+		b := Backup{Name: name, Path: path}
+		if locked(b) {
+			continue
+		}
+		// 同时删除可能已经写入的附属文件（包括过期的锁文件）
+		if err := Remove(b); err != nil {
+			return removed, fmt.Errorf("删除未完成的备份 %s 失败: %w", path, err)
+		}
+		removed = append(removed, path)
+	}
+	return removed, nil
+}
+
+// FileName 返回备份的文件或文件夹名称
+func (b Backup) FileName() string {
+	return filepath.Base(b.Path)
+}
+
+// Dir 返回备份所在的备份目录
+func (b Backup) Dir() string {
+	return filepath.Dir(b.Path)
+}
+
+// sidecarPath 返回与备份同名的附属文件路径，例如 WTF_Backup_<时间戳>.manifest.json
+func (b Backup) sidecarPath(kind string) string {
+	return filepath.Join(b.Dir(), b.Name+"."+kind+".json")
+}
+
+// ManifestPath 返回备份清单的路径，仓库快照本身就是清单
+func (b Backup) ManifestPath() string {
+	if b.Format == FormatRepo {
+		return b.Path
+	}
+	return b.sidecarPath("manifest")
+}
+
+// Files 返回备份本身及其所有附属文件（清单、备份信息等）的路径
+func Files(b Backup) ([]string, error) {
+	entries, err := os.ReadDir(b.Dir())
+	if err != nil {
+		return nil, err
+	}
+	paths := []string{b.Path}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, b.Name+".") || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		paths = append(paths, filepath.Join(b.Dir(), name))
+	}
+	return paths, nil
+}
+
+// Remove 删除备份及其所有附属文件
+func Remove(b Backup) error {
+	paths, err := Files(b)
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		if err := os.RemoveAll(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package snapshot
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCleanStagingSkipsLockedBackups(t *testing.T) {
+	dir := t.TempDir()
+	newStaging := func(s string) Backup {
+		tm, _ := time.ParseInLocation(TimeLayout, s, time.Local)
+		b := Backup{Name: NewName(tm), Path: filepath.Join(dir, NewName(tm)), Format: FormatDir, Time: tm}
+		if err := os.MkdirAll(b.Staging().Path, 0755); err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	interrupted := newStaging("2024-05-01_10-00-00")
+	running := newStaging("2024-05-01_11-00-00")
+	crashed := newStaging("2024-05-01_12-00-00")
+
+	unlock, err := Lock(running)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unlock()
+	if _, err := Lock(running); err == nil {
+		t.Errorf("同一个备份不能加锁两次")
+	}
+	// 异常退出的程序留下的锁文件过期后不再生效
+	if _, err := Lock(crashed); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-staleLockAge - time.Hour)
+	if err := os.Chtimes(crashed.LockPath(), old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	removed, err := CleanStaging(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != 2 {
+		t.Errorf("删除了 %q，期望删除 2 个临时备份", removed)
+	}
+	for _, b := range []Backup{interrupted, crashed} {
+		if _, err := os.Stat(b.Staging().Path); !os.IsNotExist(err) {
+			t.Errorf("没有删除未完成的备份 %s", b.Name)
+		}
+	}
+	if _, err := os.Stat(crashed.LockPath()); !os.IsNotExist(err) {
+		t.Errorf("没有删除过期的锁文件")
+	}
+	if _, err := os.Stat(running.Staging().Path); err != nil {
+		t.Errorf("删除了正在写入的备份: %v", err)
+	}
+
+	names, err := InProgress(dir)
+	if err != nil || len(names) != 1 || names[0] != running.Name {
+		t.Errorf("InProgress = %q, %v，期望 %s", names, err, running.Name)
+	}
+	unlock()
+	if names, _ := InProgress(dir); len(names) != 0 {
+		t.Errorf("删除锁文件后仍有正在写入的备份 %q", names)
+	}
+}
@@ -0,0 +1,82 @@
+package snapshot
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// staleLockAge 锁文件超过这个时间仍然存在时，视为写入备份的程序已经异常退出
+const staleLockAge = 12 * time.Hour
+
+// lockInfo 锁文件的内容，只用于排查问题
+type lockInfo struct {
+	PID      int       `json:"pid"`
+	Hostname string    `json:"hostname,omitempty"`
+	Started  time.Time `json:"started"`
+}
+
+// LockPath 返回写入备份期间使用的锁文件路径
+func (b Backup) LockPath() string {
+	return b.sidecarPath("lock")
+}
+
+// Lock 创建锁文件，表示备份正在写入，返回的函数用于删除锁文件。
+// 锁文件存在期间 CleanStaging 不会删除这个备份的临时文件，CollectGarbage 也不会清理对象存储
+func Lock(b Backup) (unlock func(), err error) {
+	info := lockInfo{PID: os.Getpid(), Started: time.Now()}
+	info.Hostname, _ = os.Hostname()
+	data, err := json.Marshal(info)
+	if err != nil {
+		return nil, fmt.Errorf("序列化锁文件失败: %w", err)
+	}
+
+	f, err := os.OpenFile(b.LockPath(), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		if os.IsExist(err) {
+			return nil, fmt.Errorf("备份 %s 正在由其他程序写入", b.Name)
+		}
+		return nil, fmt.Errorf("创建锁文件失败: %w", err)
+	}
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(b.LockPath())
+		return nil, fmt.Errorf("写入锁文件失败: %w", err)
+	}
+	return func() { os.Remove(b.LockPath()) }, nil
+}
+
+// locked 备份的锁文件是否存在且没有过期
+func locked(b Backup) bool {
+	info, err := os.Stat(b.LockPath())
+	return err == nil && time.Since(info.ModTime()) < staleLockAge
+}
+
+// InProgress 返回备份目录中持有锁文件（正在写入）的备份名称
+func InProgress(backupDir string) ([]string, error) {
+	entries, err := os.ReadDir(backupDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("读取备份目录失败: %w", err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), ".lock.json")
+		if !ok || entry.IsDir() || !strings.HasPrefix(name, NamePrefix) {
+			continue
+		}
+		if locked(Backup{Name: name, Path: filepath.Join(backupDir, name)}) {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
@@ -0,0 +1,82 @@
+package snapshot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Meta 备份的附加信息，保存在与备份同名的 .meta.json 文件中
+// 旧版本创建的备份可能没有这个文件，或者只有部分字段
+type Meta struct {
+	// 创建备份的程序版本
+	ToolVersion string `json:"tool_version,omitempty"`
+	// 备份的WTF文件夹
+	WtfPath string `json:"wtf_path,omitempty"`
+	// 游戏版本，例如 _retail_
+	Flavor string `json:"flavor,omitempty"`
+	// 创建备份的计算机名称
+	Hostname string `json:"hostname,omitempty"`
+	// 标签，可以通过 label:<标签> 选择备份
+	Label string `json:"label,omitempty"`
+	// 备注，例如 "11.0 版本更新前"
+	Note string `json:"note,omitempty"`
+	// 备份中的文件数量
+	Files int `json:"files,omitempty"`
+	// 备份中所有文件的总大小（未压缩）
+	Size int64 `json:"size,omitempty"`
+	// 备份耗时（秒）
+	Duration float64 `json:"duration_seconds,omitempty"`
+	// 备份时游戏是否正在运行
+	GameRunning bool `json:"game_running,omitempty"`
+
+	// 备份时的校验发现了问题（例如 SavedVariables 为空、无法解析或明显变小）
+	Suspect bool `json:"suspect,omitempty"`
+	// 校验发现的具体问题
+	Problems []string `json:"problems,omitempty"`
+	// 已固定的备份不会被清理旧备份时删除
+	Pinned bool `json:"pinned,omitempty"`
+}
+
+// MetaPath 返回备份附加信息文件的路径
+func (b Backup) MetaPath() string {
+	return b.sidecarPath("meta")
+}
+
+// LoadMeta 读取备份的附加信息，文件不存在时返回空的附加信息
+func LoadMeta(b Backup) (*Meta, error) {
+	data, err := os.ReadFile(b.MetaPath())
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return &Meta{}, nil
+		}
+		return nil, fmt.Errorf("读取备份信息失败: %w", err)
+	}
+
+	var meta Meta
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, fmt.Errorf("解析备份信息 %s 失败: %w", filepath.Base(b.MetaPath()), err)
+	}
+	return &meta, nil
+}
+
+// SaveMeta 保存备份的附加信息
+func SaveMeta(b Backup, meta *Meta) error {
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化备份信息失败: %w", err)
+	}
+
+	tmp := b.MetaPath() + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("写入备份信息失败: %w", err)
+	}
+	if err := os.Rename(tmp, b.MetaPath()); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("保存备份信息失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package snapshot
+
+import (
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Reader 备份内容的只读视图，路径使用 '/' 分隔并相对于WTF文件夹
+type Reader interface {
+	fs.FS
+	Close() error
+}
+
+// Open 打开一个备份（文件夹、压缩包或仓库快照），无需解压整个备份即可读取其中的文件
+func Open(path string) (Reader, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("无法访问备份: %w", err)
+	}
+	if info.IsDir() {
+		return dirReader{os.DirFS(path)}, nil
+	}
+
+	// 写入过程中的临时备份同样按其正式名称识别格式
+	name := strings.TrimSuffix(path, StagingSuffix)
+	switch {
+	case strings.HasSuffix(name, FormatZip.Ext()):
+		zr, err := zip.OpenReader(path)
+		if err != nil {
+			return nil, fmt.Errorf("打开zip压缩包失败: %w", err)
+		}
+		return zr, nil
+	case strings.HasSuffix(name, FormatTarGz.Ext()):
+		return openTar(path, func(r io.Reader) (io.ReadCloser, error) {
+			return gzip.NewReader(r)
+		})
+	case strings.HasSuffix(name, FormatRepo.Ext()):
+		return openRepo(path)
+	case strings.HasSuffix(name, FormatTarZst.Ext()):
+		return openTar(path, func(r io.Reader) (io.ReadCloser, error) {
+			zr, err := zstd.NewReader(r)
+			if err != nil {
+				return nil, err
+			}
+			return zr.IOReadCloser(), nil
+		})
+	default:
+		return nil, fmt.Errorf("无法识别的备份格式: %s", path)
+	}
+}
+
+// dirReader 文件夹备份，关闭时无需释放资源
+type dirReader struct {
+	fs.FS
+}
+
+func (dirReader) Close() error {
+	return nil
+}
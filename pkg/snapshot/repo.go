@@ -0,0 +1,237 @@
+package snapshot
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/lizhening/WtfBackup/pkg/logger"
+	"github.com/lizhening/WtfBackup/pkg/manifest"
+	"github.com/lizhening/WtfBackup/pkg/progress"
+	"github.com/lizhening/WtfBackup/pkg/store"
+)
+
+// ObjectsDir 仓库模式下对象存储所在的子文件夹名称（位于备份目录中）
+const ObjectsDir = "objects"
+
+// ObjectStore 返回备份目录对应的对象存储
+func ObjectStore(backupDir string) *store.Store {
+	return store.New(filepath.Join(backupDir, ObjectsDir))
+}
+
+// CreateRepo 以仓库模式备份 src 文件夹
+// 文件内容按哈希存入 dst 所在目录的对象存储，dst 只保存 路径 -> 哈希、权限和修改时间 的清单
+// ctx 被取消时停止写入，已写入的对象会在下次清理旧备份时作为未引用的对象被删除
+func CreateRepo(ctx context.Context, src, dst string, showProgress bool) error {
+	objects := ObjectStore(filepath.Dir(dst))
+
+	var bar *progress.ProgressBar
+	if showProgress {
+		total, files, err := dirSize(src)
+		if err != nil {
+			return fmt.Errorf("计算文件夹大小失败: %w", err)
+		}
+		bar = progress.NewProgressBar(total, "备份文件", filepath.Base(dst))
+		bar.SetFiles(files)
+	}
+
+	m := manifest.New()
+	var added int
+	var addedBytes int64
+	err := filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(src, path)
+		if err != nil {
+			return fmt.Errorf("计算相对路径失败: %w", err)
+		}
+
+		hash, isNew, err := objects.Put(path)
+		if err != nil {
+			return fmt.Errorf("保存 %s 失败: %w", relPath, err)
+		}
+		if isNew {
+			added++
+			addedBytes += info.Size()
+		}
+
+		m.Add(manifest.Entry{
+			Path:    filepath.ToSlash(relPath),
+			Size:    info.Size(),
+			Mode:    info.Mode().Perm(),
+			ModTime: info.ModTime(),
+			SHA256:  hash,
+		})
+		if bar != nil {
+			bar.Update(info.Size())
+			bar.FileDone(filepath.ToSlash(relPath))
+		}
+		return nil
+	})
+	if err != nil {
+		if bar != nil {
+			bar.Stop()
+		}
+		return fmt.Errorf("写入对象存储失败: %w", err)
+	}
+	if bar != nil {
+		bar.Finish()
+	}
+
+	if err := m.Save(dst); err != nil {
+		return err
+	}
+	logger.Info("共 %d 个文件，新增对象 %d 个 (%s)", len(m.Files), added, progress.FormatBytes(addedBytes))
+	return nil
+}
+
+// repoReader 基于快照清单的只读文件系统，文件内容从对象存储中读取
+type repoReader struct {
+	*tree
+	objects *store.Store
+	files   []manifest.Entry
+}
+
+// openRepo 打开仓库模式的快照
+func openRepo(path string) (*repoReader, error) {
+	m, err := manifest.Load(path)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &repoReader{
+		tree:    newTree(),
+		objects: ObjectStore(filepath.Dir(path)),
+		files:   m.Files,
+	}
+	for i, entry := range m.Files {
+		if !fs.ValidPath(entry.Path) || entry.Path == "." {
+			continue
+		}
+		r.addFile(entry.Path, entryInfo{entry}, i)
+	}
+	r.finish()
+	return r, nil
+}
+
+// Open 实现 fs.FS 接口
+func (r *repoReader) Open(name string) (fs.File, error) {
+	n, err := r.lookup("open", name)
+	if err != nil {
+		return nil, err
+	}
+	if n.info.IsDir() {
+		return r.openDir(name, n), nil
+	}
+
+	f, err := r.objects.Open(r.files[n.index].SHA256)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return &objectFile{File: f, info: n.info}, nil
+}
+
+// Close 快照清单已读入内存，无需释放资源
+func (r *repoReader) Close() error {
+	return nil
+}
+
+// objectFile 对象存储中的文件，文件信息取自清单
+type objectFile struct {
+	*os.File
+	info fs.FileInfo
+}
+
+func (f *objectFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+
+// entryInfo 将清单条目包装为 fs.FileInfo
+type entryInfo struct {
+	entry manifest.Entry
+}
+
+func (i entryInfo) Name() string       { return filepath.Base(filepath.FromSlash(i.entry.Path)) }
+func (i entryInfo) Size() int64        { return i.entry.Size }
+func (i entryInfo) Mode() fs.FileMode  { return i.entry.Mode }
+func (i entryInfo) ModTime() time.Time { return i.entry.ModTime }
+func (i entryInfo) IsDir() bool        { return false }
+func (i entryInfo) Sys() interface{}   { return nil }
+
+// CollectGarbage 删除对象存储中不再被任何快照引用的对象
+// 有备份正在写入时跳过清理：仓库备份写完清单之前，新存入的对象还没有被任何清单引用
+func CollectGarbage(backupDir string) (removed int, freed int64, err error) {
+	objects := ObjectStore(backupDir)
+	if _, err := os.Stat(objects.Dir()); os.IsNotExist(err) {
+		return 0, 0, nil
+	}
+
+	inProgress, err := InProgress(backupDir)
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(inProgress) > 0 {
+		logger.Info("备份 %s 正在写入，跳过对象清理", strings.Join(inProgress, ", "))
+		return 0, 0, nil
+	}
+
+	backups, err := List(backupDir)
+	if err != nil {
+		return 0, 0, err
+	}
+	// 已写完但还没有重命名为正式名称的仓库快照同样引用对象
+	staging, err := stagingRepos(backupDir)
+	if err != nil {
+		return 0, 0, err
+	}
+	backups = append(backups, staging...)
+
+	// 任何一个快照清单读取失败都不能删除对象，否则可能误删仍被引用的内容
+	referenced := make(map[string]bool)
+	for _, backup := range backups {
+		if backup.Format != FormatRepo {
+			continue
+		}
+		m, err := manifest.Load(backup.Path)
+		if err != nil {
+			return 0, 0, fmt.Errorf("读取快照 %s 失败，跳过对象清理: %w", backup.Name, err)
+		}
+		for _, entry := range m.Files {
+			referenced[entry.SHA256] = true
+		}
+	}
+
+	return objects.GC(referenced)
+}
+
+// stagingRepos 返回备份目录中的临时仓库快照
+func stagingRepos(backupDir string) ([]Backup, error) {
+	entries, err := os.ReadDir(backupDir)
+	if err != nil {
+		return nil, fmt.Errorf("读取备份目录失败: %w", err)
+	}
+
+	var backups []Backup
+	for _, entry := range entries {
+		fileName, ok := strings.CutSuffix(entry.Name(), StagingSuffix)
+		if !ok {
+			continue
+		}
+		name, format, t, ok := ParseName(fileName, entry.IsDir())
+		if !ok || format != FormatRepo {
+			continue
+		}
+		backups = append(backups, Backup{Name: name, Path: filepath.Join(backupDir, entry.Name()), Format: format, Time: t})
+	}
+	return backups, nil
+}
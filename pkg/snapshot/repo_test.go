@@ -0,0 +1,70 @@
+package snapshot
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCollectGarbage(t *testing.T) {
+	backupDir := t.TempDir()
+	src := filepath.Join(t.TempDir(), "WTF")
+	if err := os.MkdirAll(filepath.Join(src, "Account"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{"Config.wtf": "SET a 1", "Account/X.lua": "X = 1"} {
+		if err := os.WriteFile(filepath.Join(src, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 已写完清单但还没有重命名为正式名称的仓库快照
+	now := time.Now()
+	b := Backup{Name: NewName(now), Format: FormatRepo, Time: now}
+	b.Path = filepath.Join(backupDir, b.Name+FormatRepo.Ext())
+	if err := CreateRepo(context.Background(), src, b.Staging().Path, false); err != nil {
+		t.Fatal(err)
+	}
+
+	// 一个没有被任何快照引用的对象
+	orphan := filepath.Join(t.TempDir(), "orphan.lua")
+	if err := os.WriteFile(orphan, []byte("orphan"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	hash, _, err := ObjectStore(backupDir).Put(orphan)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 有备份正在写入时不清理
+	unlock, err := Lock(Backup{Name: NewName(now.Add(time.Second)), Path: filepath.Join(backupDir, "x")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed, _, err := CollectGarbage(backupDir); err != nil || removed != 0 {
+		t.Errorf("有备份正在写入时删除了 %d 个对象, %v", removed, err)
+	}
+	unlock()
+
+	removed, _, err := CollectGarbage(backupDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 1 || ObjectStore(backupDir).Has(hash) {
+		t.Errorf("删除了 %d 个对象，期望只删除未引用的对象", removed)
+	}
+
+	// 临时快照引用的对象仍然可以读取
+	fsys, err := Open(b.Staging().Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fsys.Close()
+	data, err := fs.ReadFile(fsys, "Account/X.lua")
+	if err != nil || string(data) != "X = 1" {
+		t.Errorf("读取临时快照中的文件得到 %q, %v", data, err)
+	}
+}
@@ -0,0 +1,133 @@
+package snapshot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dateLayouts 选择备份时支持的日期格式
+var dateLayouts = []string{
+	TimeLayout,
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// Select 按选择器从备份列表（按时间倒序）中选出一个备份
+// 支持的选择器：
+//   - 空字符串或 latest: 最新的备份
+//   - 序号: 0 表示最新的备份，1 表示上一个，依此类推；也可以写作 #1。
+//     不带 # 的数字只有小于备份数量时才视为序号，否则按时间戳前缀匹配（例如 2024）
+//   - 备份名称: 例如 WTF_Backup_2024-05-01_20-00-00（可带扩展名）
+//   - 时间戳或其前缀: 例如 2024-05-01_20-00-00、2024-05-01、20240501（匹配当天最新的备份）
+//   - before:<日期>: 指定时间之前最新的备份，例如 before:2024-05-01
+//   - label:<标签>: 带有该标签的最新的备份，不区分大小写，例如 label:pre-11.0
+func Select(backups []Backup, spec string) (Backup, error) {
+	if len(backups) == 0 {
+		return Backup{}, fmt.Errorf("没有找到备份")
+	}
+
+	spec = strings.TrimSpace(spec)
+	switch {
+	case spec == "" || spec == "latest":
+		return backups[0], nil
+
+	case strings.HasPrefix(spec, "before:"):
+		t, err := ParseTime(strings.TrimPrefix(spec, "before:"))
+		if err != nil {
+			return Backup{}, err
+		}
+		for _, b := range backups {
+			if b.Time.Before(t) {
+				return b, nil
+			}
+		}
+		return Backup{}, fmt.Errorf("没有 %s 之前的备份", t.Format("2006-01-02 15:04:05"))
+
+	case strings.HasPrefix(spec, "label:"):
+		label := strings.TrimSpace(strings.TrimPrefix(spec, "label:"))
+		for _, b := range backups {
+			meta, err := LoadMeta(b)
+			if err != nil {
+				continue
+			}
+			if meta.Label != "" && strings.EqualFold(meta.Label, label) {
+				return b, nil
+			}
+		}
+		return Backup{}, fmt.Errorf("没有标签为 %q 的备份", label)
+	}
+
+	if s, ok := strings.CutPrefix(spec, "#"); ok {
+		index, err := strconv.Atoi(s)
+		if err != nil {
+			return Backup{}, fmt.Errorf("无效的备份序号 %q", spec)
+		}
+		return selectIndex(backups, index)
+	}
+	index, err := strconv.Atoi(spec)
+	isIndex := err == nil
+	if isIndex && index >= 0 && index < len(backups) {
+		return backups[index], nil
+	}
+
+	for _, b := range backups {
+		if b.Name == spec || b.FileName() == spec {
+			return b, nil
+		}
+	}
+
+	// 时间戳前缀，忽略分隔符，因此 2024-05-01 20:00、2024-05-01T20、20240501 等写法都可以
+	if prefix, ok := timestampDigits(strings.TrimPrefix(spec, NamePrefix)); ok {
+		for _, b := range backups {
+			if digits, _ := timestampDigits(strings.TrimPrefix(b.Name, NamePrefix)); strings.HasPrefix(digits, prefix) {
+				return b, nil
+			}
+		}
+	}
+	if isIndex {
+		return selectIndex(backups, index)
+	}
+	return Backup{}, fmt.Errorf("没有找到匹配 %q 的备份", spec)
+}
+
+// selectIndex 按序号选择备份
+func selectIndex(backups []Backup, index int) (Backup, error) {
+	if index < 0 || index >= len(backups) {
+		return Backup{}, fmt.Errorf("备份序号 %d 超出范围 (共 %d 个备份)", index, len(backups))
+	}
+	return backups[index], nil
+}
+
+// timestampDigits 去掉时间戳中的分隔符，只保留数字；
+// s 不以数字开头或包含数字和分隔符以外的字符时返回 false
+func timestampDigits(s string) (string, bool) {
+	if s == "" || s[0] < '0' || s[0] > '9' {
+		return "", false
+	}
+	var sb strings.Builder
+	for _, c := range s {
+		switch {
+		case c >= '0' && c <= '9':
+			sb.WriteRune(c)
+		case strings.ContainsRune("-_: T", c):
+		default:
+			return "", false
+		}
+	}
+	return sb.String(), true
+}
+
+// ParseTime 解析日期或时间，使用本地时区
+func ParseTime(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range dateLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("无法解析时间 %q，请使用 2006-01-02 或 2006-01-02_15-04-05 格式", s)
+}
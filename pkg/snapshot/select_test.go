@@ -0,0 +1,96 @@
+package snapshot
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testBackups 返回按时间倒序排列的测试备份，dir 为备份所在的目录
+func testBackups(dir string) []Backup {
+	times := []string{
+		"2024-05-02_09-00-00",
+		"2024-05-01_20-00-00",
+		"2024-05-01_08-30-00",
+		"2024-04-30_23-59-59",
+		"2023-12-31_12-00-00",
+	}
+	backups := make([]Backup, len(times))
+	for i, s := range times {
+		tm, err := time.ParseInLocation(TimeLayout, s, time.Local)
+		if err != nil {
+			panic(err)
+		}
+		name := NewName(tm)
+		format, path := FormatDir, filepath.Join(dir, name)
+		if i == 1 {
+			format, path = FormatZip, path+".zip"
+		}
+		backups[i] = Backup{Name: name, Path: path, Format: format, Time: tm}
+	}
+	return backups
+}
+
+func TestSelect(t *testing.T) {
+	dir := t.TempDir()
+	backups := testBackups(dir)
+	if err := SaveMeta(backups[2], &Meta{Label: "Pre-11.0"}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		spec string
+		want int // 期望选中的备份序号，-1 表示应返回错误
+	}{
+		{"", 0},
+		{"latest", 0},
+		{"0", 0},
+		{"3", 3},
+		{"#3", 3},
+		{"5", -1},
+		{"#5", -1},
+		{"#x", -1},
+		{"-1", -1},
+		{"-2", -1},
+		// 超出序号范围的数字按时间戳前缀匹配
+		{"2024", 0},
+		{"2023", 4},
+		{"20240501", 1},
+		{"202405010830", 2},
+		{"2024-05-01T08", 2},
+		{"2024-05-01x", -1},
+		{"WTF_Backup_2024-05-01_08-30-00", 2},
+		{"WTF_Backup_2024-05-01_20-00-00.zip", 1},
+		{"2024-05-01_08-30-00", 2},
+		{"2024-05-01", 1},
+		{"2024-05-01 08:30", 2},
+		{"2024-04", 3},
+		{"2022-01-01", -1},
+		{"before:2024-05-01", 3},
+		{"before:2024-05-01 12:00", 2},
+		{"before:2020-01-01", -1},
+		{"before:yesterday", -1},
+		{"label:pre-11.0", 2},
+		{"label:missing", -1},
+	}
+	for _, tt := range tests {
+		got, err := Select(backups, tt.spec)
+		if tt.want < 0 {
+			if err == nil {
+				t.Errorf("Select(%q) 选中了 %s，期望返回错误", tt.spec, got.Name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Select(%q) 失败: %v", tt.spec, err)
+			continue
+		}
+		if got.Name != backups[tt.want].Name {
+			t.Errorf("Select(%q) = %s，期望 %s", tt.spec, got.Name, backups[tt.want].Name)
+		}
+	}
+
+	if _, err := Select(nil, "latest"); err == nil {
+		t.Errorf("没有备份时应返回错误")
+	}
+}
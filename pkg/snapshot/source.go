@@ -0,0 +1,50 @@
+package snapshot
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+)
+
+// Live 表示当前的WTF文件夹而不是某个备份
+const Live = "live"
+
+// Source 读取设置的一方（某个备份或当前的WTF文件夹）
+type Source struct {
+	Name string
+	fs.FS
+	close func() error
+}
+
+// Close 关闭数据源
+func (s *Source) Close() error {
+	if s.close == nil {
+		return nil
+	}
+	return s.close()
+}
+
+// OpenSource 打开当前的WTF文件夹或一个备份，spec 为 live 时使用 wtfPath，
+// 否则按 Select 从 backupDir 中选择备份
+func OpenSource(wtfPath, backupDir, spec string) (*Source, error) {
+	if spec == Live {
+		if _, err := os.Stat(wtfPath); err != nil {
+			return nil, fmt.Errorf("无法访问WTF文件夹: %w", err)
+		}
+		return &Source{Name: "WTF文件夹", FS: os.DirFS(wtfPath)}, nil
+	}
+
+	backups, err := List(backupDir)
+	if err != nil {
+		return nil, err
+	}
+	b, err := Select(backups, spec)
+	if err != nil {
+		return nil, err
+	}
+	r, err := Open(b.Path)
+	if err != nil {
+		return nil, err
+	}
+	return &Source{Name: b.Name, FS: r, close: r.Close}, nil
+}
@@ -0,0 +1,163 @@
+package snapshot
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"sync"
+)
+
+// tarReader 基于tar压缩包的只读文件系统
+// 打开时扫描一遍建立索引，读取文件时顺着解压流向前推进，
+// 因此按遍历顺序读取文件时整个压缩包只需解压一次
+type tarReader struct {
+	*tree
+	path       string
+	decompress func(io.Reader) (io.ReadCloser, error)
+
+	mu     sync.Mutex
+	file   *os.File
+	stream io.ReadCloser
+	tr     *tar.Reader
+	pos    int // 下一个待读取条目在压缩包中的序号
+}
+
+// openTar 打开tar压缩包并建立索引
+func openTar(archivePath string, decompress func(io.Reader) (io.ReadCloser, error)) (*tarReader, error) {
+	r := &tarReader{
+		tree:       newTree(),
+		path:       archivePath,
+		decompress: decompress,
+	}
+	if err := r.rewind(); err != nil {
+		return nil, err
+	}
+
+	for {
+		header, err := r.tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			r.Close()
+			return nil, fmt.Errorf("读取压缩包索引失败: %w", err)
+		}
+		index := r.pos
+		r.pos++
+
+		name := path.Clean(strings.TrimPrefix(header.Name, "./"))
+		if name == "." || !fs.ValidPath(name) {
+			continue
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			r.addDir(name).info = header.FileInfo()
+		case tar.TypeReg:
+			r.addFile(name, header.FileInfo(), index)
+		}
+	}
+
+	r.finish()
+	return r, nil
+}
+
+// rewind 从头重新打开解压流
+func (r *tarReader) rewind() error {
+	r.closeStream()
+
+	f, err := os.Open(r.path)
+	if err != nil {
+		return fmt.Errorf("打开压缩包失败: %w", err)
+	}
+	stream, err := r.decompress(f)
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("解压失败: %w", err)
+	}
+
+	r.file = f
+	r.stream = stream
+	r.tr = tar.NewReader(stream)
+	r.pos = 0
+	return nil
+}
+
+// closeStream 关闭当前的解压流
+func (r *tarReader) closeStream() {
+	if r.stream != nil {
+		r.stream.Close()
+		r.stream = nil
+	}
+	if r.file != nil {
+		r.file.Close()
+		r.file = nil
+	}
+	r.tr = nil
+}
+
+// readEntry 读取文件条目的全部内容
+func (r *tarReader) readEntry(n *node) ([]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.tr == nil || n.index < r.pos {
+		if err := r.rewind(); err != nil {
+			return nil, err
+		}
+	}
+
+	for {
+		_, err := r.tr.Next()
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, fmt.Errorf("读取压缩包失败: %w", err)
+		}
+		index := r.pos
+		r.pos++
+		if index == n.index {
+			return io.ReadAll(r.tr)
+		}
+	}
+}
+
+// Open 实现 fs.FS 接口
+func (r *tarReader) Open(name string) (fs.File, error) {
+	n, err := r.lookup("open", name)
+	if err != nil {
+		return nil, err
+	}
+	if n.info.IsDir() {
+		return r.openDir(name, n), nil
+	}
+
+	data, err := r.readEntry(n)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return &memFile{info: n.info, Reader: bytes.NewReader(data)}, nil
+}
+
+// Close 关闭压缩包
+func (r *tarReader) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closeStream()
+	return nil
+}
+
+// memFile 已读入内存的文件
+type memFile struct {
+	info fs.FileInfo
+	*bytes.Reader
+}
+
+func (f *memFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *memFile) Close() error               { return nil }
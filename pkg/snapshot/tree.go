@@ -0,0 +1,152 @@
+package snapshot
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"time"
+)
+
+// tree 压缩包、快照清单等非文件夹备份的目录索引
+// 实现了 fs.StatFS 和 fs.ReadDirFS，具体备份类型只需提供 Open
+type tree struct {
+	nodes map[string]*node
+}
+
+// node 目录索引中的一个条目
+type node struct {
+	info     fs.FileInfo
+	index    int      // 条目在备份中的序号，由具体备份类型解释，文件夹为 -1
+	children []string // 文件夹的子条目名称
+}
+
+// newTree 创建只包含根目录的索引
+func newTree() *tree {
+	return &tree{
+		nodes: map[string]*node{
+			".": {info: dirInfo{name: "."}, index: -1},
+		},
+	}
+}
+
+// addDir 登记文件夹及其所有上级文件夹
+func (t *tree) addDir(name string) *node {
+	if n, ok := t.nodes[name]; ok {
+		return n
+	}
+	n := &node{info: dirInfo{name: path.Base(name)}, index: -1}
+	t.nodes[name] = n
+	parent := t.addDir(path.Dir(name))
+	parent.children = append(parent.children, name)
+	return n
+}
+
+// addFile 登记文件
+func (t *tree) addFile(name string, info fs.FileInfo, index int) {
+	if _, ok := t.nodes[name]; ok {
+		return
+	}
+	parent := t.addDir(path.Dir(name))
+	parent.children = append(parent.children, name)
+	t.nodes[name] = &node{info: info, index: index}
+}
+
+// finish 索引建立完成后对子条目排序
+func (t *tree) finish() {
+	for _, n := range t.nodes {
+		sort.Strings(n.children)
+	}
+}
+
+// lookup 查找条目
+func (t *tree) lookup(op, name string) (*node, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	n, ok := t.nodes[name]
+	if !ok {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	return n, nil
+}
+
+// Stat 实现 fs.StatFS 接口
+func (t *tree) Stat(name string) (fs.FileInfo, error) {
+	n, err := t.lookup("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return n.info, nil
+}
+
+// ReadDir 实现 fs.ReadDirFS 接口
+func (t *tree) ReadDir(name string) ([]fs.DirEntry, error) {
+	n, err := t.lookup("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	if !n.info.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fmt.Errorf("不是文件夹")}
+	}
+	return t.dirEntries(n), nil
+}
+
+// dirEntries 返回文件夹的子条目
+func (t *tree) dirEntries(n *node) []fs.DirEntry {
+	list := make([]fs.DirEntry, 0, len(n.children))
+	for _, child := range n.children {
+		list = append(list, fs.FileInfoToDirEntry(t.nodes[child].info))
+	}
+	return list
+}
+
+// openDir 以 fs.ReadDirFile 的形式打开文件夹
+func (t *tree) openDir(name string, n *node) fs.File {
+	return &treeDir{t: t, name: name, n: n}
+}
+
+// treeDir 索引中的文件夹
+type treeDir struct {
+	t      *tree
+	name   string
+	n      *node
+	offset int
+}
+
+func (d *treeDir) Stat() (fs.FileInfo, error) { return d.n.info, nil }
+func (d *treeDir) Close() error               { return nil }
+
+func (d *treeDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: fmt.Errorf("是文件夹")}
+}
+
+// ReadDir 实现 fs.ReadDirFile 接口
+func (d *treeDir) ReadDir(count int) ([]fs.DirEntry, error) {
+	list := d.t.dirEntries(d.n)[d.offset:]
+	if count <= 0 {
+		d.offset += len(list)
+		return list, nil
+	}
+	if len(list) == 0 {
+		return nil, io.EOF
+	}
+	if count > len(list) {
+		count = len(list)
+	}
+	d.offset += count
+	return list[:count], nil
+}
+
+// dirInfo 备份中未显式记录的文件夹信息
+type dirInfo struct {
+	name string
+}
+
+func (i dirInfo) Name() string       { return i.name }
+func (i dirInfo) Size() int64        { return 0 }
+func (i dirInfo) Mode() fs.FileMode  { return fs.ModeDir | 0755 }
+func (i dirInfo) ModTime() time.Time { return time.Time{} }
+func (i dirInfo) IsDir() bool        { return true }
+func (i dirInfo) Sys() interface{}   { return nil }
@@ -0,0 +1,137 @@
+package store
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Store 内容寻址的对象存储，每个文件内容按其 SHA-256 只保存一份
+// 对象路径为 <dir>/<哈希前两位>/<完整哈希>
+type Store struct {
+	dir string
+}
+
+// New 创建对象存储
+func New(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// Dir 返回对象存储所在的文件夹
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+// Path 返回对象的存储路径
+func (s *Store) Path(hash string) string {
+	if len(hash) < 2 {
+		return filepath.Join(s.dir, hash)
+	}
+	return filepath.Join(s.dir, hash[:2], hash)
+}
+
+// Has 检查对象是否存在
+func (s *Store) Has(hash string) bool {
+	_, err := os.Stat(s.Path(hash))
+	return err == nil
+}
+
+// HashFile 计算文件内容的 SHA-256
+func HashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	return Hash(f)
+}
+
+// Hash 计算数据流的 SHA-256
+func Hash(r io.Reader) (string, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Put 将文件内容存入对象存储，已存在相同内容时不再重复写入
+// 返回对象哈希以及本次是否写入了新对象
+func (s *Store) Put(path string) (hash string, added bool, err error) {
+	hash, err = HashFile(path)
+	if err != nil {
+		return "", false, fmt.Errorf("计算文件哈希失败: %w", err)
+	}
+	if s.Has(hash) {
+		return hash, false, nil
+	}
+
+	src, err := os.Open(path)
+	if err != nil {
+		return "", false, fmt.Errorf("打开源文件失败: %w", err)
+	}
+	defer src.Close()
+
+	objPath := s.Path(hash)
+	if err := os.MkdirAll(filepath.Dir(objPath), 0755); err != nil {
+		return "", false, fmt.Errorf("创建对象目录失败: %w", err)
+	}
+
+	// 先写入临时文件，校验哈希后再重命名，避免留下内容不符的对象
+	tmp, err := os.CreateTemp(filepath.Dir(objPath), hash+".tmp*")
+	if err != nil {
+		return "", false, fmt.Errorf("创建对象文件失败: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, h), src); err != nil {
+		tmp.Close()
+		return "", false, fmt.Errorf("写入对象失败: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", false, fmt.Errorf("写入对象失败: %w", err)
+	}
+	if written := hex.EncodeToString(h.Sum(nil)); written != hash {
+		return "", false, fmt.Errorf("文件 %s 在备份过程中发生了变化", path)
+	}
+	if err := os.Rename(tmp.Name(), objPath); err != nil {
+		return "", false, fmt.Errorf("保存对象失败: %w", err)
+	}
+	return hash, true, nil
+}
+
+// Open 打开对象
+func (s *Store) Open(hash string) (*os.File, error) {
+	f, err := os.Open(s.Path(hash))
+	if err != nil {
+		return nil, fmt.Errorf("打开对象 %s 失败: %w", hash, err)
+	}
+	return f, nil
+}
+
+// GC 删除所有未被引用的对象，返回删除的对象数量和释放的空间
+func (s *Store) GC(referenced map[string]bool) (removed int, freed int64, err error) {
+	if _, err := os.Stat(s.dir); os.IsNotExist(err) {
+		return 0, 0, nil
+	}
+
+	err = filepath.Walk(s.dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || referenced[info.Name()] {
+			return nil
+		}
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("删除对象失败: %w", err)
+		}
+		removed++
+		freed += info.Size()
+		return nil
+	})
+	return removed, freed, err
+}
@@ -0,0 +1,165 @@
+package wtf
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Location 文件在WTF文件夹中所属的账号、服务器和角色
+// 账号通用的文件（例如 Account/<账号>/SavedVariables/...）Realm 和 Character 为空
+type Location struct {
+	Account   string
+	Realm     string
+	Character string
+}
+
+// AccountWide 是否为账号通用的文件
+func (l Location) AccountWide() bool {
+	return l.Character == ""
+}
+
+// ParsePath 从相对于WTF文件夹的路径中解析出账号、服务器和角色，
+// 不在 Account 文件夹下的文件（例如 Config.wtf）返回 false
+func ParsePath(relPath string) (Location, bool) {
+	parts := strings.Split(strings.ReplaceAll(relPath, "\\", "/"), "/")
+	if len(parts) < 3 || parts[0] != "Account" {
+		return Location{}, false
+	}
+
+	loc := Location{Account: parts[1]}
+	// Account/<账号>/<服务器>/<角色>/<文件>，账号下的 SavedVariables 等文件夹不是服务器
+	if len(parts) >= 5 && !isAccountDir(parts[2]) {
+		loc.Realm = parts[2]
+		loc.Character = parts[3]
+	}
+	return loc, true
+}
+
+// ParseCharacter 解析 <账号>/<服务器>/<角色> 形式的角色，可以带有 Account/ 前缀
+func ParseCharacter(s string) (Location, error) {
+	s = strings.Trim(strings.ReplaceAll(s, "\\", "/"), "/")
+	s = strings.TrimPrefix(s, "Account/")
+	parts := strings.Split(s, "/")
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		return Location{}, fmt.Errorf("无效的角色 %q，请使用 <账号>/<服务器>/<角色> 格式", s)
+	}
+	for _, part := range parts {
+		if part == "." || part == ".." {
+			return Location{}, fmt.Errorf("无效的角色 %q", s)
+		}
+	}
+	return Location{Account: parts[0], Realm: parts[1], Character: parts[2]}, nil
+}
+
+// Dir 返回角色（或账号）文件夹相对于WTF文件夹的路径，使用 '/' 分隔
+func (l Location) Dir() string {
+	if l.AccountWide() {
+		return path.Join("Account", l.Account)
+	}
+	return path.Join("Account", l.Account, l.Realm, l.Character)
+}
+
+// String 返回 <账号>/<服务器>/<角色> 形式的说明
+func (l Location) String() string {
+	if l.AccountWide() {
+		return l.Account
+	}
+	return l.Account + "/" + l.Realm + "/" + l.Character
+}
+
+// isAccountDir 账号文件夹下不属于服务器的子文件夹
+func isAccountDir(name string) bool {
+	return name == "SavedVariables" || name == "SavedVariablesPerCharacter"
+}
+
+// Scope 恢复范围，按账号、服务器和角色筛选文件
+// 筛选条件支持 path.Match 的通配符，不区分大小写，空字符串匹配所有。
+// 指定了服务器或角色时默认不包含账号通用的文件，因为这些文件会影响账号下的所有角色，
+// 需要时设置 AccountWide 显式包含
+type Scope struct {
+	Account   string
+	Realm     string
+	Character string
+	// 不包含账号通用的文件
+	NoAccountWide bool
+	// 指定了服务器或角色时仍然包含账号通用的文件
+	AccountWide bool
+	// 不包含角色的文件
+	NoCharacters bool
+}
+
+// includesAccountWide 是否包含账号通用的文件
+func (s Scope) includesAccountWide() bool {
+	if s.NoAccountWide {
+		return false
+	}
+	return s.AccountWide || (s.Realm == "" && s.Character == "")
+}
+
+// Validate 检查筛选条件中的通配符是否有效
+func (s Scope) Validate() error {
+	for _, pattern := range []string{s.Account, s.Realm, s.Character} {
+		if _, err := path.Match(strings.ToLower(pattern), ""); err != nil {
+			return fmt.Errorf("无效的筛选条件 %q: %w", pattern, err)
+		}
+	}
+	if s.NoAccountWide && s.AccountWide {
+		return fmt.Errorf("不能同时包含和排除账号通用设置")
+	}
+	if !s.includesAccountWide() && s.NoCharacters {
+		return fmt.Errorf("账号通用设置和角色设置不能同时排除 (指定服务器或角色时默认不包含账号通用设置)")
+	}
+	return nil
+}
+
+// Match 检查文件是否在恢复范围内
+func (s Scope) Match(relPath string) bool {
+	loc, ok := ParsePath(relPath)
+	if !ok || !matchPattern(s.Account, loc.Account) {
+		return false
+	}
+	if loc.AccountWide() {
+		return s.includesAccountWide()
+	}
+	return !s.NoCharacters && matchPattern(s.Realm, loc.Realm) && matchPattern(s.Character, loc.Character)
+}
+
+// IsAll 是否不做任何筛选
+func (s Scope) IsAll() bool {
+	return s.Account == "" && s.Realm == "" && s.Character == "" && !s.NoAccountWide && !s.NoCharacters
+}
+
+// String 返回恢复范围的说明
+func (s Scope) String() string {
+	if s.IsAll() {
+		return "全部"
+	}
+
+	var parts []string
+	for _, f := range []struct{ name, pattern string }{
+		{"账号", s.Account}, {"服务器", s.Realm}, {"角色", s.Character},
+	} {
+		if f.pattern != "" {
+			parts = append(parts, f.name+" "+f.pattern)
+		}
+	}
+	if !s.includesAccountWide() {
+		parts = append(parts, "不含账号通用设置")
+	} else if s.AccountWide && (s.Realm != "" || s.Character != "") {
+		parts = append(parts, "含账号通用设置")
+	}
+	if s.NoCharacters {
+		parts = append(parts, "不含角色设置")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// matchPattern 不区分大小写地匹配通配符，空的通配符匹配所有
+func matchPattern(pattern, name string) bool {
+	if pattern == "" {
+		return true
+	}
+	matched, _ := path.Match(strings.ToLower(pattern), strings.ToLower(name))
+	return matched
+}
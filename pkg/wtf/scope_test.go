@@ -0,0 +1,107 @@
+package wtf
+
+import "testing"
+
+func TestParsePath(t *testing.T) {
+	tests := []struct {
+		path string
+		want Location
+		ok   bool
+	}{
+		{"Config.wtf", Location{}, false},
+		{"Account/ACC/SavedVariables/X.lua", Location{Account: "ACC"}, true},
+		{"Account/ACC/bindings-cache.wtf", Location{Account: "ACC"}, true},
+		{`Account\ACC\Realm\Char\SavedVariables\X.lua`, Location{Account: "ACC", Realm: "Realm", Character: "Char"}, true},
+		{"Account/ACC/Realm/Char/layout-local.txt", Location{Account: "ACC", Realm: "Realm", Character: "Char"}, true},
+	}
+	for _, tt := range tests {
+		got, ok := ParsePath(tt.path)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("ParsePath(%q) = %+v, %v，期望 %+v, %v", tt.path, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestScopeMatch(t *testing.T) {
+	const (
+		config      = "Config.wtf"
+		accountWide = "Account/ACC/SavedVariables/WeakAuras.lua"
+		char1       = "Account/ACC/Stormrage/Alice/SavedVariables/WeakAuras.lua"
+		char2       = "Account/ACC/Illidan/Bob/SavedVariables/WeakAuras.lua"
+		other       = "Account/OTHER/SavedVariables/WeakAuras.lua"
+	)
+
+	tests := []struct {
+		name  string
+		scope Scope
+		match map[string]bool
+	}{
+		{"全部", Scope{}, map[string]bool{config: false, accountWide: true, char1: true, char2: true, other: true}},
+		{"账号", Scope{Account: "acc"}, map[string]bool{accountWide: true, char1: true, char2: true, other: false}},
+		{"通配符", Scope{Character: "A*"}, map[string]bool{char1: true, char2: false}},
+		{"服务器", Scope{Realm: "illidan"}, map[string]bool{char1: false, char2: true}},
+		// 指定服务器或角色时默认不包含账号通用的文件
+		{"角色不含账号通用设置", Scope{Character: "Alice"}, map[string]bool{accountWide: false, char1: true}},
+		{"服务器不含账号通用设置", Scope{Realm: "Illidan"}, map[string]bool{accountWide: false, other: false, char2: true}},
+		{"显式包含账号通用设置", Scope{Realm: "Illidan", AccountWide: true}, map[string]bool{accountWide: true, other: true, char1: false, char2: true}},
+		{"不含账号通用设置", Scope{NoAccountWide: true}, map[string]bool{accountWide: false, char1: true}},
+		{"不含角色设置", Scope{NoCharacters: true}, map[string]bool{accountWide: true, char1: false, char2: false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for path, want := range tt.match {
+				if got := tt.scope.Match(path); got != want {
+					t.Errorf("Match(%q) = %v，期望 %v", path, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestScopeValidate(t *testing.T) {
+	if err := (Scope{Character: "[a"}).Validate(); err == nil {
+		t.Errorf("无效的通配符应返回错误")
+	}
+	if err := (Scope{NoAccountWide: true, NoCharacters: true}).Validate(); err == nil {
+		t.Errorf("同时排除账号通用设置和角色设置应返回错误")
+	}
+	if err := (Scope{Realm: "Illidan", NoCharacters: true}).Validate(); err == nil {
+		t.Errorf("指定服务器并排除角色设置时没有可以恢复的文件，应返回错误")
+	}
+	if err := (Scope{Realm: "Illidan", NoCharacters: true, AccountWide: true}).Validate(); err != nil {
+		t.Errorf("显式包含账号通用设置时返回了 %v", err)
+	}
+	if err := (Scope{Account: "ACC", Character: "Al*"}).Validate(); err != nil {
+		t.Errorf("有效的筛选条件返回了 %v", err)
+	}
+}
+
+func TestParseCharacter(t *testing.T) {
+	got, err := ParseCharacter("Account/ACC/Stormrage/Alice/")
+	if want := (Location{Account: "ACC", Realm: "Stormrage", Character: "Alice"}); err != nil || got != want {
+		t.Errorf("ParseCharacter = %+v, %v，期望 %+v", got, err, want)
+	}
+	for _, s := range []string{"ACC/Alice", "ACC//Alice", "ACC/../Alice", "a/b/c/d"} {
+		if _, err := ParseCharacter(s); err == nil {
+			t.Errorf("ParseCharacter(%q) 应失败", s)
+		}
+	}
+}
+
+func TestScopeString(t *testing.T) {
+	tests := []struct {
+		scope Scope
+		want  string
+	}{
+		{Scope{}, "全部"},
+		{Scope{AccountWide: true}, "全部"},
+		{Scope{Account: "ACC", NoCharacters: true}, "账号 ACC, 不含角色设置"},
+		{Scope{Character: "Alice"}, "角色 Alice, 不含账号通用设置"},
+		{Scope{Character: "Alice", AccountWide: true}, "角色 Alice, 含账号通用设置"},
+	}
+	for _, tt := range tests {
+		if got := tt.scope.String(); got != tt.want {
+			t.Errorf("%+v.String() = %q，期望 %q", tt.scope, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package wtf
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/lizhening/WtfBackup/pkg/luasv"
+)
+
+// ErrEmptyFile SavedVariables 文件为空（游戏崩溃时可能留下）
+var ErrEmptyFile = errors.New("文件为空")
+
+// ShrinkRatio 与上一个备份相比，文件大小低于该比例时视为被截断
+const ShrinkRatio = 0.5
+
+// ShrinkMinSize 只检查上一个备份中不小于该大小的文件是否明显变小，避免小文件的正常波动误报
+const ShrinkMinSize = 4 * 1024
+
+// IsSavedVariablesFile 检查路径是否为 SavedVariables 或 SavedVariablesPerCharacter 中的 Lua 文件
+func IsSavedVariablesFile(relPath string) bool {
+	relPath = strings.ReplaceAll(relPath, "\\", "/")
+	if !strings.HasSuffix(relPath, ".lua") {
+		return false
+	}
+	dir := path.Base(path.Dir(relPath))
+	return dir == "SavedVariables" || dir == "SavedVariablesPerCharacter"
+}
+
+// CheckSavedVariables 检查 SavedVariables 文件内容不为空且可以被解析
+func CheckSavedVariables(data []byte) error {
+	if len(strings.TrimSpace(string(data))) == 0 {
+		return ErrEmptyFile
+	}
+	if _, err := luasv.Parse(data); err != nil {
+		return fmt.Errorf("无法解析: %w", err)
+	}
+	return nil
+}
+
+// CheckShrink 检查文件与上一个备份相比是否明显变小
+func CheckShrink(size, previousSize int64) error {
+	if previousSize < ShrinkMinSize || float64(size) >= float64(previousSize)*ShrinkRatio {
+		return nil
+	}
+	return fmt.Errorf("文件大小从 %d 字节减少到 %d 字节", previousSize, size)
+}
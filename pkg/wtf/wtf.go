@@ -0,0 +1,45 @@
+package wtf
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// IsAddonFile 检查文件是否与指定插件相关
+// WTF文件夹通常有以下与插件相关的路径：
+// 1. Account/<账号>/SavedVariables/<插件名>.lua
+// 2. Account/<账号>/<服务器>/<角色>/SavedVariables/<插件名>.lua
+// 3. Account/<账号>/SavedVariablesPerCharacter/<插件名>.lua
+// 4. Account/<账号>/<服务器>/<角色>/SavedVariablesPerCharacter/<插件名>.lua
+func IsAddonFile(relPath, addonName string) bool {
+	// 这些是插件配置文件的常见位置
+	patterns := []string{
+		// 全局设置
+		fmt.Sprintf("Account/*/SavedVariables/%s.lua", addonName),
+		// 角色特定设置
+		fmt.Sprintf("Account/*/*/*/SavedVariables/%s.lua", addonName),
+		// 角色特定设置 (另一种类型)
+		fmt.Sprintf("Account/*/SavedVariablesPerCharacter/%s.lua", addonName),
+		// 角色特定设置 (另一种类型)
+		fmt.Sprintf("Account/*/*/*/SavedVariablesPerCharacter/%s.lua", addonName),
+	}
+
+	// 将路径分隔符统一为 '/'
+	relPath = filepath.ToSlash(relPath)
+
+	// 检查文件是否匹配任何模式
+	for _, pattern := range patterns {
+		if matched, _ := filepath.Match(pattern, relPath); matched {
+			return true
+		}
+	}
+
+	// 处理可能的子文件夹和其他相关文件
+	if strings.Contains(relPath, fmt.Sprintf("/SavedVariables/%s_", addonName)) ||
+		strings.Contains(relPath, fmt.Sprintf("/SavedVariablesPerCharacter/%s_", addonName)) {
+		return true
+	}
+
+	return false
+}
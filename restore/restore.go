@@ -1,30 +1,83 @@
 package restore
 
 import (
+	"context"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/lizhening/WtfBackup/config"
 	"github.com/lizhening/WtfBackup/pkg/fileutil"
 	"github.com/lizhening/WtfBackup/pkg/logger"
+	"github.com/lizhening/WtfBackup/pkg/process"
+	"github.com/lizhening/WtfBackup/pkg/progress"
+	"github.com/lizhening/WtfBackup/pkg/snapshot"
+	"github.com/lizhening/WtfBackup/pkg/wtf"
+	"github.com/lizhening/WtfBackup/undo"
 )
 
+// Options 恢复选项
+type Options struct {
+	// 要恢复的备份，支持 snapshot.Select 的所有选择器，默认为最新的备份
+	From string
+	// 显示进度条
+	ShowProgress bool
+	// 即使备份中的 SavedVariables 文件损坏也继续恢复
+	Force bool
+	// 恢复范围，只恢复匹配的账号、服务器和角色，默认恢复全部
+	Scope wtf.Scope
+	// 游戏正在运行时仍然恢复
+	AllowRunning bool
+	// 检查游戏是否正在运行时使用的进程列表，为 nil 时使用 process.Default
+	Processes process.Lister
+}
+
 // RestoreAddon 从备份中恢复特定插件的配置
-func RestoreAddon(cfg config.Config, addonName string, fileOp fileutil.FileOperator, showProgress bool) error {
-	// 找到最新的备份
-	backups, err := findBackups(cfg.BackupDir)
+func RestoreAddon(ctx context.Context, cfg config.Config, addonName string, fileOp fileutil.FileOperator, opts Options) error {
+	return RestoreAddons(ctx, cfg, []string{addonName}, fileOp, opts)
+}
+
+// RestoreAddons 从同一个备份中恢复多个插件的配置
+// 写入WTF文件夹之前会为即将覆盖或创建的文件保存快照，可以使用 undo 命令撤销。
+// ctx 被取消时停止恢复，已恢复的文件同样可以撤销。
+// 恢复过程中发布 start、file、skip 和 done 等事件
+func RestoreAddons(ctx context.Context, cfg config.Config, addons []string, fileOp fileutil.FileOperator, opts Options) (err error) {
+	start := time.Now()
+	var summary progress.Summary
+	progress.Emit(progress.Event{Type: progress.EventStart, Op: "restore", Path: cfg.WtfPath, Message: strings.Join(addons, ", ")})
+	defer func() {
+		summary.Duration = time.Since(start).Seconds()
+		progress.Emit(progress.Done("restore", summary, err))
+	}()
+
+	if err := opts.Scope.Validate(); err != nil {
+		return err
+	}
+	// 试运行不写入文件，只给出警告
+	if err := process.CheckBeforeWrite(opts.Processes, opts.AllowRunning || fileutil.IsDryRun(fileOp)); err != nil {
+		return err
+	}
+
+	// 找到要恢复的备份
+	selected, err := FindBackup(cfg.BackupDir, opts.From)
 	if err != nil {
-		return fmt.Errorf("查找备份失败: %w", err)
+		return err
 	}
-	if len(backups) == 0 {
-		return fmt.Errorf("没有找到备份")
+	summary.Backup = selected.Name
+	logger.Info("将从备份 %s 中恢复插件 %s 的配置", selected.FileName(), strings.Join(addons, ", "))
+	if !opts.Scope.IsAll() {
+		logger.Info("恢复范围: %s", opts.Scope)
 	}
 
-	// 获取最新的备份
-	latestBackup := backups[0]
-	logger.Info("将从备份 %s 中恢复插件 %s 的配置", filepath.Base(latestBackup), addonName)
+	// 打开备份，压缩包备份无需完整解压即可读取其中的文件
+	src, err := snapshot.Open(selected.Path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
 
 	// 准备查找插件相关的文件夹和文件
 	// WTF文件夹通常有以下与插件相关的路径：
@@ -33,117 +86,182 @@ func RestoreAddon(cfg config.Config, addonName string, fileOp fileutil.FileOpera
 	// 3. Account/<账号>/SavedVariablesPerCharacter/<插件名>.lua
 	// 4. Account/<账号>/<服务器>/<角色>/SavedVariablesPerCharacter/<插件名>.lua
 
-	// 遍历备份文件夹找到所有与插件相关的配置
-	err = fileOp.Walk(latestBackup, func(path string, info os.FileInfo, err error) error {
+	// 遍历备份找到所有与插件相关的配置文件
+	var files []string
+	var skipped int
+	found := make(map[string]bool)
+	addonOf := make(map[string]string)
+	err = fs.WalkDir(src, ".", func(relPath string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
-
-		// 获取相对路径
-		relPath, err := filepath.Rel(latestBackup, path)
-		if err != nil {
-			return err
+		if d.IsDir() {
+			return nil
 		}
-
-		// 检查是否是插件相关文件
-		if isAddonFile(relPath, addonName) {
-			// 构建目标路径
-			destPath := filepath.Join(cfg.WtfPath, relPath)
-			destDir := filepath.Dir(destPath)
-
-			// 创建必要的文件夹
-			if err := fileOp.EnsureDir(destDir); err != nil {
-				return fmt.Errorf("创建文件夹 %s 失败: %w", destDir, err)
+		for _, addon := range addons {
+			if !wtf.IsAddonFile(relPath, addon) {
+				continue
 			}
-
-			// 如果是文件，则复制
-			if !info.IsDir() {
-				var err error
-				if showProgress {
-					err = fileOp.CopyWithProgress(path, destPath)
-				} else {
-					err = fileOp.Copy(path, destPath)
+			found[addon] = true
+			if opts.Scope.Match(relPath) {
+				files = append(files, relPath)
+				addonOf[relPath] = addon
+			} else {
+				// 不在恢复范围内的账号、服务器或角色保持不变
+				skipped++
+				var size int64
+				if info, err := d.Info(); err == nil {
+					size = info.Size()
 				}
-				if err != nil {
-					return fmt.Errorf("复制文件 %s 至 %s 失败: %w", path, destPath, err)
+				progress.Emit(progress.Event{Type: progress.EventSkip, Op: "restore", Path: relPath, Size: size, Message: "不在恢复范围内"})
+				if dryRun, ok := fileOp.(*fileutil.DryRunOperator); ok {
+					dryRun.Record(fileutil.Op{
+						Kind:   fileutil.OpSkip,
+						Path:   filepath.Join(cfg.WtfPath, filepath.FromSlash(relPath)),
+						Source: relPath,
+						Size:   size,
+						Reason: "不在恢复范围内",
+					})
 				}
-				logger.Info("已恢复: %s", relPath)
 			}
+			break
 		}
-
 		return nil
 	})
-
 	if err != nil {
 		return fmt.Errorf("恢复过程中出错: %w", err)
 	}
+	summary.Skipped = skipped
+	for _, addon := range addons {
+		if !found[addon] {
+			warn("备份 %s 中没有插件 %s 的配置", selected.FileName(), addon)
+		}
+	}
+	if skipped > 0 {
+		logger.Info("跳过 %d 个不在恢复范围内的文件", skipped)
+	}
+	if len(files) == 0 {
+		if skipped > 0 {
+			warn("没有匹配恢复范围 (%s) 的文件", opts.Scope)
+		}
+		return nil
+	}
 
-	return nil
-}
+	// 恢复前检查备份中的文件，避免用损坏的文件覆盖当前的配置
+	if err := checkFiles(selected, src, files, opts.Force); err != nil {
+		return err
+	}
 
-// isAddonFile 检查文件是否与指定插件相关
-func isAddonFile(relPath, addonName string) bool {
-	// 这些是插件配置文件的常见位置
-	patterns := []string{
-		// 全局设置
-		fmt.Sprintf("Account/*/SavedVariables/%s.lua", addonName),
-		// 角色特定设置
-		fmt.Sprintf("Account/*/*/*/SavedVariables/%s.lua", addonName),
-		// 角色特定设置 (另一种类型)
-		fmt.Sprintf("Account/*/SavedVariablesPerCharacter/%s.lua", addonName),
-		// 角色特定设置 (另一种类型)
-		fmt.Sprintf("Account/*/*/*/SavedVariablesPerCharacter/%s.lua", addonName),
-	}
-
-	// 将路径分隔符统一为 '/'
-	relPath = filepath.ToSlash(relPath)
-
-	// 检查文件是否匹配任何模式
-	for _, pattern := range patterns {
-		if matched, _ := filepath.Match(pattern, relPath); matched {
-			return true
+	// 保存即将被覆盖或创建的文件，以便撤销这次恢复（试运行时不需要）
+	if !fileutil.IsDryRun(fileOp) {
+		action := fmt.Sprintf("恢复插件 %s (来自 %s)", strings.Join(addons, ", "), selected.Name)
+		if !opts.Scope.IsAll() {
+			action = fmt.Sprintf("恢复插件 %s (来自 %s，范围: %s)", strings.Join(addons, ", "), selected.Name, opts.Scope)
+		}
+		s, err := undo.Begin(ctx, cfg.BackupDir, cfg.WtfPath, action, files, fileOp)
+		if err != nil {
+			return err
 		}
+		logger.Info("已保存恢复前的文件，可以使用 undo 命令撤销这次恢复 (快照 %s)", s.ID)
 	}
 
-	// 处理可能的子文件夹和其他相关文件
-	if strings.Contains(relPath, fmt.Sprintf("/SavedVariables/%s_", addonName)) ||
-		strings.Contains(relPath, fmt.Sprintf("/SavedVariablesPerCharacter/%s_", addonName)) {
-		return true
+	for _, relPath := range files {
+		// 构建目标路径
+		destPath := filepath.Join(cfg.WtfPath, filepath.FromSlash(relPath))
+		destDir := filepath.Dir(destPath)
+
+		// 创建必要的文件夹
+		if err := fileOp.EnsureDir(destDir); err != nil {
+			return fmt.Errorf("创建文件夹 %s 失败: %w", destDir, err)
+		}
+
+		if err := fileOp.CopyFromFS(ctx, src, relPath, destPath, opts.ShowProgress); err != nil {
+			if ctx.Err() != nil && !fileutil.IsDryRun(fileOp) {
+				return fmt.Errorf("恢复已中断，已恢复的文件可以使用 undo 命令撤销: %w", err)
+			}
+			return fmt.Errorf("恢复过程中出错: 复制文件 %s 至 %s 失败: %w", relPath, destPath, err)
+		}
+		var size int64
+		if info, err := fs.Stat(src, relPath); err == nil {
+			size = info.Size()
+		}
+		if !fileutil.IsDryRun(fileOp) {
+			logger.With(logger.Fields{
+				logger.FieldOp:     "restore",
+				logger.FieldBackup: selected.Name,
+				logger.FieldAddon:  addonOf[relPath],
+				logger.FieldPath:   relPath,
+				logger.FieldBytes:  size,
+			}).Info("已恢复: %s", relPath)
+		}
+		summary.Files++
+		summary.Bytes += size
+		progress.Emit(progress.Event{Type: progress.EventFile, Op: "restore", Path: relPath, Size: size,
+			Bytes: summary.Bytes, Files: summary.Files, TotalFiles: len(files)})
 	}
 
-	return false
+	return nil
 }
 
-// findBackups 查找并按时间排序所有备份
-func findBackups(backupDir string) ([]string, error) {
-	// 确保备份目录存在
-	if _, err := os.Stat(backupDir); os.IsNotExist(err) {
-		return nil, fmt.Errorf("备份目录不存在")
-	}
+// warn 记录警告日志并发布 warning 事件
+func warn(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	logger.With(logger.Fields{logger.FieldOp: "restore"}).Warn("%s", msg)
+	progress.Emit(progress.Event{Type: progress.EventWarning, Op: "restore", Message: msg})
+}
 
-	// 获取备份目录下的所有条目
-	entries, err := os.ReadDir(backupDir)
-	if err != nil {
-		return nil, err
+// checkFiles 检查要恢复的 SavedVariables 文件是否为空或无法解析，
+// 发现损坏的文件时拒绝恢复，force 为 true 时只给出警告
+func checkFiles(b snapshot.Backup, src fs.FS, files []string, force bool) error {
+	if meta, err := snapshot.LoadMeta(b); err != nil {
+		warn("%v", err)
+	} else if meta.Suspect {
+		warn("备份 %s 在备份时已被标记为可疑", b.FileName())
 	}
 
-	// 过滤出备份文件夹
-	var backups []string
-	for _, entry := range entries {
-		if entry.IsDir() && strings.HasPrefix(entry.Name(), "WTF_Backup_") {
-			backups = append(backups, filepath.Join(backupDir, entry.Name()))
+	var corrupt []string
+	for _, relPath := range files {
+		if !wtf.IsSavedVariablesFile(relPath) {
+			continue
+		}
+		data, err := fs.ReadFile(src, relPath)
+		if err != nil {
+			return fmt.Errorf("读取文件 %s 失败: %w", relPath, err)
+		}
+		if err := wtf.CheckSavedVariables(data); err != nil {
+			corrupt = append(corrupt, fmt.Sprintf("%s: %v", relPath, err))
 		}
 	}
+	if len(corrupt) == 0 {
+		return nil
+	}
 
-	// 按时间倒序排列（最新的备份在最前面）
-	// 由于备份文件夹的命名格式中包含时间戳，所以可以直接按名称排序
-	for i := 0; i < len(backups)-1; i++ {
-		for j := i + 1; j < len(backups); j++ {
-			if filepath.Base(backups[i]) < filepath.Base(backups[j]) {
-				backups[i], backups[j] = backups[j], backups[i]
-			}
-		}
+	for _, problem := range corrupt {
+		warn("备份中的文件可能已损坏: %s", problem)
+	}
+	if !force {
+		return fmt.Errorf("备份中有 %d 个文件可能已损坏，已取消恢复 (使用 -force 强制恢复，或使用 -from 选择其他备份)", len(corrupt))
+	}
+	warn("已指定 -force，仍将恢复这些文件")
+	return nil
+}
+
+// findBackups 查找并按时间排序所有备份（包括文件夹和压缩包）
+func findBackups(backupDir string) ([]snapshot.Backup, error) {
+	// 确保备份目录存在
+	if _, err := os.Stat(backupDir); os.IsNotExist(err) {
+		return nil, fmt.Errorf("备份目录不存在")
 	}
 
-	return backups, nil
+	// 按时间倒序排列（最新的备份在最前面）
+	return snapshot.List(backupDir)
+}
+
+// FindBackup 按选择器查找备份，选择器为空时返回最新的备份
+func FindBackup(backupDir, from string) (snapshot.Backup, error) {
+	backups, err := findBackups(backupDir)
+	if err != nil {
+		return snapshot.Backup{}, fmt.Errorf("查找备份失败: %w", err)
+	}
+	return snapshot.Select(backups, from)
 }
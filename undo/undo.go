@@ -0,0 +1,287 @@
+package undo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lizhening/WtfBackup/pkg/fileutil"
+	"github.com/lizhening/WtfBackup/pkg/logger"
+)
+
+// DirName 备份目录中保存恢复前快照的文件夹
+const DirName = "WTF_Undo"
+
+// Keep 最多保留的恢复前快照数量
+const Keep = 20
+
+// Version 当前日志格式版本
+const Version = 1
+
+const (
+	journalFile = "journal.json"
+	filesDir    = "files"
+	idLayout    = "2006-01-02_15-04-05"
+)
+
+// File 一个即将被修改的文件
+type File struct {
+	// 相对于WTF文件夹的路径，使用 '/' 分隔
+	Path string `json:"path"`
+	// 修改前文件是否已存在：存在时快照中保存了原文件，不存在时撤销会删除该文件
+	Existed bool `json:"existed"`
+}
+
+// Journal 一次恢复操作的日志
+type Journal struct {
+	Version int       `json:"version"`
+	Created time.Time `json:"created"`
+	// 操作说明，例如 "恢复插件 ElvUI (来自 WTF_Backup_2024-05-01_20-00-00)"
+	Action string `json:"action"`
+	// 被修改的WTF文件夹
+	WtfPath string `json:"wtf_path"`
+	Files   []File `json:"files"`
+	// 已撤销的时间，未撤销时为空
+	Undone *time.Time `json:"undone,omitempty"`
+}
+
+// Snapshot 一次恢复前保存的快照
+type Snapshot struct {
+	// 快照ID，即创建时间，例如 2024-05-01_20-00-00
+	ID string
+	// 快照文件夹的完整路径
+	Path string
+	Journal
+}
+
+// Begin 在修改WTF文件夹之前为即将覆盖或创建的文件保存快照
+// files 为相对于 wtfPath 的路径，已存在的文件会被复制到快照中，不存在的文件只记录在日志里
+func Begin(ctx context.Context, backupDir, wtfPath, action string, files []string, fileOp fileutil.FileOperator) (*Snapshot, error) {
+	root := filepath.Join(backupDir, DirName)
+	if err := fileOp.EnsureDir(root); err != nil {
+		return nil, err
+	}
+
+	s, err := create(root, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("创建恢复前快照失败: %w", err)
+	}
+	s.Journal = Journal{
+		Version: Version,
+		Created: time.Now(),
+		Action:  action,
+		WtfPath: wtfPath,
+	}
+
+	for _, relPath := range files {
+		relPath = filepath.ToSlash(relPath)
+		livePath := filepath.Join(wtfPath, filepath.FromSlash(relPath))
+
+		file := File{Path: relPath}
+		info, err := os.Stat(livePath)
+		switch {
+		case err == nil && info.Mode().IsRegular():
+			if err := fileOp.Copy(ctx, livePath, s.filePath(relPath)); err != nil {
+				os.RemoveAll(s.Path)
+				return nil, fmt.Errorf("保存 %s 失败: %w", relPath, err)
+			}
+			file.Existed = true
+		case err == nil:
+			os.RemoveAll(s.Path)
+			return nil, fmt.Errorf("%s 不是普通文件", livePath)
+		case !errors.Is(err, fs.ErrNotExist):
+			os.RemoveAll(s.Path)
+			return nil, fmt.Errorf("读取 %s 失败: %w", livePath, err)
+		}
+		s.Files = append(s.Files, file)
+	}
+
+	// 日志最后写入，没有日志的快照不会被列出
+	if err := s.save(); err != nil {
+		os.RemoveAll(s.Path)
+		return nil, err
+	}
+
+	prune(root, Keep)
+	return s, nil
+}
+
+// create 以时间为ID创建快照文件夹，同一秒内有多个快照时追加序号
+func create(root string, t time.Time) (*Snapshot, error) {
+	base := t.Format(idLayout)
+	for i := 0; ; i++ {
+		id := base
+		if i > 0 {
+			id = fmt.Sprintf("%s_%d", base, i)
+		}
+		path := filepath.Join(root, id)
+		err := os.Mkdir(path, 0755)
+		if err == nil {
+			return &Snapshot{ID: id, Path: path}, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return nil, err
+		}
+	}
+}
+
+// filePath 返回快照中保存原文件的路径
+func (s *Snapshot) filePath(relPath string) string {
+	return filepath.Join(s.Path, filesDir, filepath.FromSlash(relPath))
+}
+
+// save 保存日志，先写入临时文件再重命名
+func (s *Snapshot) save() error {
+	data, err := json.MarshalIndent(&s.Journal, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化恢复日志失败: %w", err)
+	}
+
+	path := filepath.Join(s.Path, journalFile)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("写入恢复日志失败: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("保存恢复日志失败: %w", err)
+	}
+	return nil
+}
+
+// load 读取快照文件夹中的日志
+func load(path string) (*Snapshot, error) {
+	data, err := os.ReadFile(filepath.Join(path, journalFile))
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Snapshot{ID: filepath.Base(path), Path: path}
+	if err := json.Unmarshal(data, &s.Journal); err != nil {
+		return nil, fmt.Errorf("解析恢复日志 %s 失败: %w", path, err)
+	}
+	if s.Version > Version {
+		return nil, fmt.Errorf("恢复日志 %s 的版本 %d 过新，当前仅支持版本 %d", path, s.Version, Version)
+	}
+	return s, nil
+}
+
+// List 列出所有恢复前快照，按时间倒序排列（最新的快照在最前面）
+func List(backupDir string) ([]*Snapshot, error) {
+	root := filepath.Join(backupDir, DirName)
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("读取恢复前快照失败: %w", err)
+	}
+
+	var snapshots []*Snapshot
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		s, err := load(filepath.Join(root, entry.Name()))
+		if err != nil {
+			if !errors.Is(err, fs.ErrNotExist) {
+				logger.Warn("%v", err)
+			}
+			continue
+		}
+		snapshots = append(snapshots, s)
+	}
+
+	sort.SliceStable(snapshots, func(i, j int) bool {
+		if !snapshots[i].Created.Equal(snapshots[j].Created) {
+			return snapshots[i].Created.After(snapshots[j].Created)
+		}
+		return snapshots[i].ID > snapshots[j].ID
+	})
+	return snapshots, nil
+}
+
+// Select 按选择器选出一个快照
+// 空字符串或 latest 表示最近一次尚未撤销的恢复，数字为序号（0 为最新的快照），其他值按快照ID匹配
+func Select(snapshots []*Snapshot, spec string) (*Snapshot, error) {
+	if len(snapshots) == 0 {
+		return nil, fmt.Errorf("没有可以撤销的恢复操作")
+	}
+
+	spec = strings.TrimSpace(spec)
+	if spec == "" || spec == "latest" {
+		for _, s := range snapshots {
+			if s.Undone == nil {
+				return s, nil
+			}
+		}
+		return nil, fmt.Errorf("所有恢复操作都已撤销")
+	}
+
+	if index, err := strconv.Atoi(spec); err == nil {
+		if index < 0 || index >= len(snapshots) {
+			return nil, fmt.Errorf("快照序号 %d 超出范围 (共 %d 个快照)", index, len(snapshots))
+		}
+		return snapshots[index], nil
+	}
+
+	for _, s := range snapshots {
+		if s.ID == spec {
+			return s, nil
+		}
+	}
+	return nil, fmt.Errorf("没有找到快照 %q", spec)
+}
+
+// Undo 将快照中记录的文件恢复到修改前的状态：
+// 原来存在的文件用快照中的副本覆盖，原来不存在的文件被删除
+func Undo(ctx context.Context, s *Snapshot, fileOp fileutil.FileOperator) error {
+	var failed int
+	for _, file := range s.Files {
+		livePath := filepath.Join(s.WtfPath, filepath.FromSlash(file.Path))
+		if file.Existed {
+			if err := fileOp.Copy(ctx, s.filePath(file.Path), livePath); err != nil {
+				logger.Error("还原 %s 失败: %v", file.Path, err)
+				failed++
+				continue
+			}
+			logger.Info("已还原: %s", file.Path)
+		} else {
+			if err := os.Remove(livePath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				logger.Error("删除 %s 失败: %v", file.Path, err)
+				failed++
+				continue
+			}
+			logger.Info("已删除恢复时新建的文件: %s", file.Path)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d 个文件撤销失败", failed)
+	}
+
+	now := time.Now()
+	s.Undone = &now
+	return s.save()
+}
+
+// prune 删除超出保留数量的旧快照
+func prune(root string, keep int) {
+	snapshots, err := List(filepath.Dir(root))
+	if err != nil || len(snapshots) <= keep {
+		return
+	}
+	for _, s := range snapshots[keep:] {
+		logger.Debug("删除旧的恢复前快照: %s", s.Path)
+		if err := os.RemoveAll(s.Path); err != nil {
+			logger.Warn("删除旧的恢复前快照失败 %s: %v", s.Path, err)
+		}
+	}
+}
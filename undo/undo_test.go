@@ -0,0 +1,148 @@
+package undo
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lizhening/WtfBackup/pkg/fileutil"
+)
+
+const addonPath = "Account/ACC/SavedVariables/Addon.lua"
+
+// writeFile 写入 WTF 文件夹中的文件
+func writeFile(t *testing.T, wtfPath, relPath, content string) {
+	t.Helper()
+	path := filepath.Join(wtfPath, filepath.FromSlash(relPath))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// readFile 读取 WTF 文件夹中的文件，文件不存在时返回 false
+func readFile(t *testing.T, wtfPath, relPath string) (string, bool) {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(wtfPath, filepath.FromSlash(relPath)))
+	if os.IsNotExist(err) {
+		return "", false
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data), true
+}
+
+func newFileOp() fileutil.FileOperator {
+	return fileutil.NewDefaultFileOperator(32*1024, 1)
+}
+
+func TestBeginUndo(t *testing.T) {
+	ctx := context.Background()
+	backupDir, wtfPath := t.TempDir(), t.TempDir()
+	newPath := "Account/ACC/Realm/Char/SavedVariables/New.lua"
+	writeFile(t, wtfPath, addonPath, "AddonDB = { old = true }")
+
+	s, err := Begin(ctx, backupDir, wtfPath, "恢复插件 Addon", []string{addonPath, newPath}, newFileOp())
+	if err != nil {
+		t.Fatalf("保存恢复前快照失败: %v", err)
+	}
+	want := []File{{Path: addonPath, Existed: true}, {Path: newPath, Existed: false}}
+	if len(s.Files) != len(want) || s.Files[0] != want[0] || s.Files[1] != want[1] {
+		t.Errorf("快照中的文件为 %v，期望 %v", s.Files, want)
+	}
+
+	// 恢复覆盖已有的文件并创建新文件
+	writeFile(t, wtfPath, addonPath, "AddonDB = { restored = true }")
+	writeFile(t, wtfPath, newPath, "NewDB = {}")
+
+	if err := Undo(ctx, s, newFileOp()); err != nil {
+		t.Fatalf("撤销失败: %v", err)
+	}
+	if got, _ := readFile(t, wtfPath, addonPath); got != "AddonDB = { old = true }" {
+		t.Errorf("撤销后 %s 的内容为 %q，期望原来的内容", addonPath, got)
+	}
+	if _, ok := readFile(t, wtfPath, newPath); ok {
+		t.Errorf("撤销后恢复时新建的文件 %s 应被删除", newPath)
+	}
+
+	snapshots, err := List(backupDir)
+	if err != nil || len(snapshots) != 1 {
+		t.Fatalf("找到 %d 个快照: %v", len(snapshots), err)
+	}
+	if snapshots[0].Undone == nil {
+		t.Errorf("撤销的时间应保存到恢复日志中")
+	}
+	if _, err := Select(snapshots, ""); err == nil {
+		t.Errorf("所有恢复操作都已撤销时 latest 应返回错误")
+	}
+}
+
+func TestUndoEarlierSnapshotByIndex(t *testing.T) {
+	ctx := context.Background()
+	backupDir, wtfPath := t.TempDir(), t.TempDir()
+	writeFile(t, wtfPath, addonPath, "v1")
+
+	// 连续两次恢复
+	for _, content := range []string{"v2", "v3"} {
+		if _, err := Begin(ctx, backupDir, wtfPath, "恢复 "+content, []string{addonPath}, newFileOp()); err != nil {
+			t.Fatal(err)
+		}
+		writeFile(t, wtfPath, addonPath, content)
+	}
+
+	snapshots, err := List(backupDir)
+	if err != nil || len(snapshots) != 2 {
+		t.Fatalf("找到 %d 个快照: %v", len(snapshots), err)
+	}
+	if latest, _ := Select(snapshots, ""); latest != snapshots[0] || latest.Action != "恢复 v3" {
+		t.Errorf("latest 应选择最近一次恢复")
+	}
+
+	s, err := Select(snapshots, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Action != "恢复 v2" {
+		t.Fatalf("序号 1 选择了 %q，期望较早的一次恢复", s.Action)
+	}
+	if err := Undo(ctx, s, newFileOp()); err != nil {
+		t.Fatalf("撤销失败: %v", err)
+	}
+	if got, _ := readFile(t, wtfPath, addonPath); got != "v1" {
+		t.Errorf("撤销较早的恢复后内容为 %q，期望 v1", got)
+	}
+
+	if _, err := Select(snapshots, "2"); err == nil {
+		t.Errorf("超出范围的序号应返回错误")
+	}
+	if got, err := Select(snapshots, s.ID); err != nil || got != s {
+		t.Errorf("按快照ID选择失败: %v", err)
+	}
+}
+
+func TestBeginPrunesOldSnapshots(t *testing.T) {
+	ctx := context.Background()
+	backupDir, wtfPath := t.TempDir(), t.TempDir()
+	writeFile(t, wtfPath, addonPath, "v")
+
+	for i := 0; i < Keep+3; i++ {
+		if _, err := Begin(ctx, backupDir, wtfPath, "恢复", []string{addonPath}, newFileOp()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	snapshots, err := List(backupDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshots) != Keep {
+		t.Errorf("保留了 %d 个快照，期望 %d 个", len(snapshots), Keep)
+	}
+	entries, _ := os.ReadDir(filepath.Join(backupDir, DirName))
+	if len(entries) != Keep {
+		t.Errorf("快照文件夹中有 %d 项，期望 %d 项", len(entries), Keep)
+	}
+}